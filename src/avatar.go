@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"main/src/utils"
+	"mime"
+	"mime/multipart"
+	"net/http"
+)
+
+// avatarVariable holds the hash of the last avatar uploaded to the project.
+const avatarVariable = "avatar_sha256"
+
+// maxAvatarSize is the largest avatar accepted by GitLab.
+const maxAvatarSize = 200 << 10
+
+// avatarExtensions maps the image types accepted by GitLab to the extension it checks.
+var avatarExtensions = map[string]string{
+	"image/png":  "png",
+	"image/jpeg": "jpg",
+	"image/gif":  "gif",
+	"image/webp": "webp",
+}
+
+// DownloadAvatar returns the avatar image of the source repository and its file extension.
+func (g *Project) DownloadAvatar(ctx context.Context) ([]byte, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, g.SourceRepository.AvatarURL, nil)
+	if err != nil {
+		return nil, "", err
+	}
+
+	res, err := utils.DoRequest(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("downloading avatar: status %d", res.StatusCode)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	extension, ok := avatarExtensions[mediaType]
+	if !ok {
+		return nil, "", fmt.Errorf("avatar type %s is not supported", mediaType)
+	}
+
+	image, err := io.ReadAll(io.LimitReader(res.Body, maxAvatarSize+1))
+	if err != nil {
+		return nil, "", err
+	}
+
+	if len(image) > maxAvatarSize {
+		return nil, "", fmt.Errorf("avatar is larger than %d bytes", maxAvatarSize)
+	}
+
+	return image, extension, nil
+}
+
+// UploadAvatar replaces the avatar of the project.
+func (g *Project) UploadAvatar(ctx context.Context, image []byte, extension string) error {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+
+	part, err := writer.CreateFormFile("avatar", "avatar."+extension)
+	if err != nil {
+		return err
+	}
+
+	if _, err := part.Write(image); err != nil {
+		return err
+	}
+
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	urlPath := fmt.Sprintf("/api/v4/projects/%d", *g.DestinationRepository.ID)
+	res, err := g.Destination.RequestBody(ctx, http.MethodPut, urlPath, writer.FormDataContentType(), body.Bytes())
+	if err != nil {
+		return err
+	}
+
+	if res.Status != http.StatusOK {
+		return fmt.Errorf("uploading avatar: status %d: %s", res.Status, res.Body)
+	}
+
+	return nil
+}
+
+// syncAvatar uploads the avatar of the source repository to the project, when it changed since the last upload.
+func syncAvatar(ctx context.Context, prj *Project, log *slog.Logger) error {
+	if len(prj.SourceRepository.AvatarURL) == 0 {
+		return nil
+	}
+
+	image, extension, err := prj.DownloadAvatar(ctx)
+	if err != nil {
+		return err
+	}
+
+	sum := sha256.Sum256(image)
+	hash := hex.EncodeToString(sum[:])
+
+	previous, err := prj.Destination.GetProjectVariable(ctx, *prj.DestinationRepository.ID, avatarVariable)
+	if err != nil {
+		return err
+	}
+
+	if previous != nil && *previous == hash {
+		return nil
+	}
+
+	log.Info("Uploading avatar", "url", prj.SourceRepository.AvatarURL)
+	if err := prj.UploadAvatar(ctx, image, extension); err != nil {
+		return err
+	}
+
+	if previous == nil {
+		return prj.Destination.CreateProjectVariable(ctx, *prj.DestinationRepository.ID, avatarVariable, hash)
+	}
+	return prj.Destination.SetProjectVariable(ctx, *prj.DestinationRepository.ID, avatarVariable, hash)
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"main/src/sources"
+	"os"
+	"path/filepath"
+)
+
+// Checkpoint records how far a run has progressed, so an interrupted run can
+// be resumed right after the last completed repository.
+type Checkpoint struct {
+	path string
+
+	Group    int    `json:"group"`
+	Source   string `json:"source"`
+	Username string `json:"username"`
+
+	// Cursor is the pagination request of the page that contains Repository,
+	// nil when it is the first page.
+	Cursor     *sources.PaginationResponse `json:"cursor"`
+	Repository string                      `json:"repository"`
+	Count      int                         `json:"count"`
+}
+
+// NewCheckpoint returns the checkpoint stored at path. With an empty path, nothing is stored.
+func NewCheckpoint(path string) *Checkpoint {
+	return &Checkpoint{path: path}
+}
+
+func (c *Checkpoint) Load() error {
+	content, err := os.ReadFile(c.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading checkpoint: %w", err)
+	}
+
+	if err := json.Unmarshal(content, c); err != nil {
+		return fmt.Errorf("parsing checkpoint: %w", err)
+	}
+
+	return nil
+}
+
+func (c *Checkpoint) Save() error {
+	if len(c.path) == 0 {
+		return nil
+	}
+
+	content, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return fmt.Errorf("creating checkpoint directory: %w", err)
+	}
+
+	// Write to a temporary file first so an interruption never leaves a half-written checkpoint
+	tmpPath := c.path + ".tmp"
+	if err := os.WriteFile(tmpPath, content, 0644); err != nil {
+		return fmt.Errorf("writing checkpoint: %w", err)
+	}
+
+	return os.Rename(tmpPath, c.path)
+}
+
+func (c *Checkpoint) Remove() error {
+	if len(c.path) == 0 {
+		return nil
+	}
+
+	if err := os.Remove(c.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+// Resume returns the pagination cursor, the last completed repository and
+// the repository counter to continue the given group from. It returns zero
+// values when the checkpoint does not refer to that group.
+func (c *Checkpoint) Resume(groupIndex int, groupCfg ConfigGroup) (*sources.PaginationResponse, string, int) {
+	if c.Group != groupIndex || c.Source != groupCfg.Source || c.Username != groupCfg.Username {
+		return nil, "", 1
+	}
+
+	if c.Count < 1 {
+		return c.Cursor, c.Repository, 1
+	}
+
+	return c.Cursor, c.Repository, c.Count
+}
+
+// Completed marks a repository of the given group as done.
+func (c *Checkpoint) Completed(groupIndex int, groupCfg ConfigGroup, cursor *sources.PaginationResponse, repository string, count int) error {
+	c.Group = groupIndex
+	c.Source = groupCfg.Source
+	c.Username = groupCfg.Username
+	c.Cursor = cursor
+	c.Repository = repository
+	c.Count = count
+
+	return c.Save()
+}
+
+// CompletedGroup marks the given group as done, moving the checkpoint to the start of the next one.
+func (c *Checkpoint) CompletedGroup(groupIndex int) error {
+	c.Group = groupIndex + 1
+	c.Source = ""
+	c.Username = ""
+	c.Cursor = nil
+	c.Repository = ""
+	c.Count = 0
+
+	return c.Save()
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"main/src/sources"
+	"main/src/utils"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckpoint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+	alice := ConfigGroup{Source: sources.GitHubID, Username: "alice"}
+	bob := ConfigGroup{Source: sources.HuggingFaceID, Username: "bob"}
+
+	// The third page of a listing, as the source returns it along with its repositories
+	cursor := &sources.PaginationResponse{
+		Repositories: []sources.SourceRepository{{Name: "project"}},
+		NextPage:     3,
+		NextCursor:   utils.Pointer("abc"),
+		Metadata:     map[string]string{"type": sources.TypeDataset},
+	}
+
+	checkpoint := NewCheckpoint(path)
+	if err := checkpoint.Completed(0, alice, cursor, "project", 42); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := NewCheckpoint(path)
+	if err := loaded.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	resumeCursor, repository, count := loaded.Resume(0, alice)
+	if !sameCursor(resumeCursor, cursor) || repository != "project" || count != 42 {
+		t.Errorf("Resume = %+v, %q, %d, want the third page, %q, %d", resumeCursor, repository, count, "project", 42)
+	}
+
+	// Another group starts from its first page
+	if resumeCursor, repository, count := loaded.Resume(1, bob); resumeCursor != nil || len(repository) > 0 || count != 1 {
+		t.Errorf("Resume of another group = %+v, %q, %d, want the first page", resumeCursor, repository, count)
+	}
+
+	if err := loaded.CompletedGroup(0); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded = NewCheckpoint(path)
+	if err := loaded.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	if resumeCursor, repository, count := loaded.Resume(0, alice); resumeCursor != nil || len(repository) > 0 || count != 1 {
+		t.Errorf("Resume of a completed group = %+v, %q, %d, want the first page", resumeCursor, repository, count)
+	}
+	if loaded.Group != 1 {
+		t.Errorf("Group = %d after completing group 0, want 1", loaded.Group)
+	}
+
+	if err := loaded.Remove(); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewCheckpoint(path).Load(); err != nil {
+		t.Errorf("loading a removed checkpoint: %v", err)
+	}
+}
+
+func TestCheckpointDisabled(t *testing.T) {
+	checkpoint := NewCheckpoint("")
+	if err := checkpoint.Completed(0, ConfigGroup{Source: sources.GitHubID, Username: "alice"}, nil, "project", 2); err != nil {
+		t.Errorf("Completed: %v", err)
+	}
+	if err := checkpoint.Remove(); err != nil {
+		t.Errorf("Remove: %v", err)
+	}
+}
+
+func TestSameCursor(t *testing.T) {
+	page := func(nextPage int, nextCursor *string, metadata map[string]string) *sources.PaginationResponse {
+		return &sources.PaginationResponse{NextPage: nextPage, NextCursor: nextCursor, Metadata: metadata}
+	}
+
+	tests := []struct {
+		desc string
+		a, b *sources.PaginationResponse
+		want bool
+	}{
+		{"first pages", nil, nil, true},
+		{"first page and another", nil, page(2, nil, nil), false},
+		{"same page", page(2, nil, nil), page(2, nil, nil), true},
+		{"other page", page(2, nil, nil), page(3, nil, nil), false},
+		{"same cursor", page(0, utils.Pointer("abc"), nil), page(0, utils.Pointer("abc"), nil), true},
+		{"other cursor", page(0, utils.Pointer("abc"), nil), page(0, utils.Pointer("def"), nil), false},
+		{"cursor and none", page(0, utils.Pointer("abc"), nil), page(0, nil, nil), false},
+		{"empty metadata", page(2, nil, nil), page(2, nil, map[string]string{}), true},
+		{"other metadata", page(0, nil, map[string]string{"type": "model"}), page(0, nil, map[string]string{"type": "dataset"}), false},
+	}
+
+	for _, tt := range tests {
+		if got := sameCursor(tt.a, tt.b); got != tt.want {
+			t.Errorf("%s: sameCursor = %t, want %t", tt.desc, got, tt.want)
+		}
+	}
+}
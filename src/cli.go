@@ -0,0 +1,423 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"log/slog"
+	"main/src/sources"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+)
+
+const (
+	OnlyGit      = "git"
+	OnlyWiki     = "wiki"
+	OnlyReleases = "releases"
+)
+
+// Options holds the flags shared by all commands.
+type Options struct {
+	ConfigPath string
+	Source     string
+	Group      string
+	Repo       string
+	Only       string
+}
+
+type Command struct {
+	Name        string
+	Description string
+	Run         func(args []string)
+}
+
+var commands = []Command{
+	{Name: "sync", Description: "sync the repositories to GitLab (default)", Run: runSync},
+	{Name: "serve", Description: "run as a daemon, syncing the groups on their schedule", Run: runServe},
+	{Name: "plan", Description: "show what a sync would do without changing anything", Run: runPlan},
+	{Name: "verify", Description: "compare the branches and tags of the sources with their GitLab backups", Run: runVerify},
+	{Name: "list", Description: "list the source repositories and their GitLab projects", Run: runList},
+	{Name: "restore", Description: "push a GitLab backup to a target repository", Run: runRestore},
+}
+
+func RunCLI(args []string) {
+	// Without a command, sync everything
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		runSync(args)
+		return
+	}
+
+	for _, cmd := range commands {
+		if cmd.Name == args[0] {
+			cmd.Run(args[1:])
+			return
+		}
+	}
+
+	if args[0] != "help" {
+		fmt.Fprintf(os.Stderr, "unknown command %s\n\n", args[0])
+	}
+	usage()
+	os.Exit(2)
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: git-backup <command> [flags]")
+	fmt.Fprintln(os.Stderr, "\nCommands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-8s %s\n", cmd.Name, cmd.Description)
+	}
+	fmt.Fprintln(os.Stderr, "\nRun 'git-backup <command> --help' for the flags of a command.")
+}
+
+// NewFlagSet creates the flags of a command, including the ones shared by all commands.
+func NewFlagSet(name string) (*flag.FlagSet, *Options) {
+	opts := &Options{}
+
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	flags.StringVar(&opts.ConfigPath, "config", "", "path of the configuration file")
+	flags.StringVar(&opts.Source, "source", "", "only evaluate groups from this source")
+	flags.StringVar(&opts.Group, "group", "", "only evaluate the group with this username")
+	flags.StringVar(&opts.Repo, "repo", "", "only evaluate the repository with this name")
+	flags.StringVar(&opts.Only, "only", "", "only sync one part of the repositories: git, wiki or releases")
+
+	return flags, opts
+}
+
+func (o *Options) Validate() error {
+	switch o.Only {
+	case "", OnlyGit, OnlyWiki, OnlyReleases:
+	default:
+		return fmt.Errorf("--only must be one of git, wiki or releases")
+	}
+
+	if len(o.Source) > 0 && o.Source != sources.GitHubID && o.Source != sources.HuggingFaceID {
+		return fmt.Errorf("--source %s is not valid", o.Source)
+	}
+
+	return nil
+}
+
+// MatchGroup reports whether the group passes the --source and --group filters.
+func (o *Options) MatchGroup(group ConfigGroup) bool {
+	if len(o.Source) > 0 && o.Source != group.Source {
+		return false
+	}
+
+	return len(o.Group) == 0 || strings.EqualFold(o.Group, group.Username)
+}
+
+// Filtered reports whether the run is limited to some sources, groups or repositories.
+func (o *Options) Filtered() bool {
+	return len(o.Source) > 0 || len(o.Group) > 0 || len(o.Repo) > 0
+}
+
+// MatchRepository reports whether the repository passes the --repo filter.
+func (o *Options) MatchRepository(name string) bool {
+	return len(o.Repo) == 0 || strings.EqualFold(o.Repo, name)
+}
+
+// signalContext returns a context canceled on SIGINT or SIGTERM. The default
+// handling is restored after the first signal, so a second one exits at once.
+func signalContext() (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	return ctx, stop
+}
+
+func parseFlags(flags *flag.FlagSet, opts *Options, args []string) {
+	_ = flags.Parse(args)
+
+	if err := opts.Validate(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func runSync(args []string) {
+	flags, opts := NewFlagSet("sync")
+	resume := flags.Bool("resume", false, "resume the previous run right after the last completed repository")
+	parseFlags(flags, opts, args)
+
+	config := loadConfiguration(opts.ConfigPath)
+
+	if *resume && opts.Filtered() {
+		log.Fatal("--resume cannot be combined with --source, --group or --repo")
+	}
+	if *resume && len(*config.Checkpoint.Path) == 0 {
+		log.Fatal("--resume needs a checkpoint path in the configuration")
+	}
+
+	checkpoint := NewCheckpoint(*config.Checkpoint.Path)
+	if *resume {
+		if err := checkpoint.Load(); err != nil {
+			log.Fatal("Checkpoint error:", err)
+		}
+	}
+
+	if err := Status.Load(*config.Status.Path); err != nil {
+		slog.Error("Loading status", "error", err)
+	}
+
+	ctx, stop := signalContext()
+	defer stop()
+
+	report, err := SyncGroups(ctx, config, opts, checkpoint, "sync", nil)
+	if errors.Is(err, context.Canceled) {
+		slog.Warn("Run interrupted, continue it with --resume")
+		os.Exit(130)
+	} else if err != nil {
+		log.Fatal(err)
+	}
+
+	if failed := report.Failed(); failed > 0 {
+		slog.Error("Run finished with failures", "failed", failed)
+		os.Exit(1)
+	}
+}
+
+func runPlan(args []string) {
+	flags, opts := NewFlagSet("plan")
+	output := flags.String("output", "", "write the plan as JSON to this file")
+	parseFlags(flags, opts, args)
+
+	config := loadConfiguration(opts.ConfigPath)
+	gitlab, dufs := newDestinations(config)
+	srcs := newSources(config)
+
+	ctx, stop := signalContext()
+	defer stop()
+
+	plan := &Plan{Groups: make([]PlanGroup, 0)}
+	for _, configRepo := range config.Groups {
+		if !opts.MatchGroup(configRepo) {
+			continue
+		}
+
+		source, configSource := srcs[configRepo.Source], config.GetSourceConfig(configRepo.Source)
+
+		slog.Info("Planning group", "source", configRepo.Source, "group", configRepo.Username)
+		plan.Add(PlanUser(ctx, gitlab, dufs, configSource, configRepo, source, opts))
+	}
+
+	if ctx.Err() != nil {
+		log.Fatal("plan interrupted")
+	}
+
+	plan.Print()
+
+	if len(*output) > 0 {
+		if err := plan.Export(*output); err != nil {
+			log.Fatal("Exporting plan:", err)
+		}
+	}
+}
+
+func runVerify(args []string) {
+	flags, opts := NewFlagSet("verify")
+	parseFlags(flags, opts, args)
+
+	config := loadConfiguration(opts.ConfigPath)
+	gitlab, dufs := newDestinations(config)
+	srcs := newSources(config)
+
+	ctx, stop := signalContext()
+	defer stop()
+
+	failed := 0
+	forEachRepository(ctx, config, srcs, gitlab, opts, func(configRepo ConfigGroup, remote sources.SourceRepository, name string, cfg ConfigRepo, reason string) {
+		if len(reason) > 0 {
+			return
+		}
+
+		prj := NewProject(gitlab, dufs, *configRepo.GitLabGroupID, name, srcs[configRepo.Source], configRepo.Username, remote, cfg)
+		problems, err := VerifyRepo(ctx, prj)
+		if err != nil {
+			problems = []string{err.Error()}
+		}
+
+		if len(problems) == 0 {
+			fmt.Printf("OK   %s/%s\n", configRepo.Username, remote.Name)
+			return
+		}
+
+		failed++
+		fmt.Printf("FAIL %s/%s\n", configRepo.Username, remote.Name)
+		for _, problem := range problems {
+			fmt.Printf("  - %s\n", problem)
+		}
+	})
+
+	if failed > 0 {
+		fmt.Printf("\n%d repositories differ from their source\n", failed)
+		os.Exit(1)
+	}
+}
+
+func runList(args []string) {
+	flags, opts := NewFlagSet("list")
+	parseFlags(flags, opts, args)
+
+	config := loadConfiguration(opts.ConfigPath)
+	gitlab, dufs := newDestinations(config)
+	srcs := newSources(config)
+
+	ctx, stop := signalContext()
+	defer stop()
+
+	forEachRepository(ctx, config, srcs, gitlab, opts, func(configRepo ConfigGroup, remote sources.SourceRepository, gitlabName string, cfg ConfigRepo, reason string) {
+		name := fmt.Sprintf("%s/%s/%s", configRepo.Source, configRepo.Username, remote.Name)
+		if len(reason) > 0 {
+			fmt.Printf("%s\tskipped: %s\n", name, reason)
+			return
+		}
+
+		prj := NewProject(gitlab, dufs, *configRepo.GitLabGroupID, gitlabName, srcs[configRepo.Source], configRepo.Username, remote, cfg)
+		repoID, err := prj.RetrieveExistingRepo(ctx)
+		switch {
+		case err != nil:
+			fmt.Printf("%s\terror: %v\n", name, err)
+		case repoID == -1:
+			fmt.Printf("%s\tnot in GitLab\n", name)
+		default:
+			fmt.Printf("%s\tproject %d (%s)\n", name, repoID, *prj.DestinationRepository.PathWithNamespace)
+		}
+	})
+}
+
+func runRestore(args []string) {
+	flags, opts := NewFlagSet("restore")
+	target := flags.String("to", "", "url of the repository to push the backup to, including any credentials")
+	parseFlags(flags, opts, args)
+
+	if len(opts.Repo) == 0 || len(*target) == 0 {
+		log.Fatal("restore requires --repo and --to")
+	}
+
+	config := loadConfiguration(opts.ConfigPath)
+	gitlab, dufs := newDestinations(config)
+	srcs := newSources(config)
+
+	ctx, stop := signalContext()
+	defer stop()
+
+	type match struct {
+		configRepo ConfigGroup
+		remote     sources.SourceRepository
+		name       string
+		cfg        ConfigRepo
+		reason     string
+	}
+
+	// Every match would be pushed to the same target, so --repo must match a single repository
+	matches := make([]match, 0)
+	forEachRepository(ctx, config, srcs, gitlab, opts, func(configRepo ConfigGroup, remote sources.SourceRepository, name string, cfg ConfigRepo, reason string) {
+		matches = append(matches, match{configRepo, remote, name, cfg, reason})
+	})
+
+	if ctx.Err() != nil {
+		os.Exit(130)
+	}
+
+	switch len(matches) {
+	case 0:
+		log.Fatal("no repository matches --repo")
+	case 1:
+	default:
+		groups := make([]string, 0, len(matches))
+		for _, m := range matches {
+			groups = append(groups, m.configRepo.Source+"/"+m.configRepo.Username)
+		}
+		log.Fatalf("--repo matches repositories in %s, use --source and --group to pick one", strings.Join(groups, ", "))
+	}
+
+	m := matches[0]
+	if len(m.reason) > 0 {
+		log.Fatalf("repository %s is skipped by the sync (%s), refusing to restore it", m.remote.Name, m.reason)
+	}
+
+	prj := NewProject(gitlab, dufs, *m.configRepo.GitLabGroupID, m.name, srcs[m.configRepo.Source], m.configRepo.Username, m.remote, m.cfg)
+	if err := restoreProject(ctx, prj, *target); err != nil {
+		log.Fatal(err)
+	}
+	slog.Info("Restored repository", "source", m.configRepo.Source, "group", m.configRepo.Username, "repo", m.remote.Name)
+}
+
+// restoreProject pushes the GitLab backup of the project to target.
+func restoreProject(ctx context.Context, prj *Project, target string) error {
+	defer prj.Prune()
+
+	repoID, err := prj.RetrieveExistingRepo(ctx)
+	if err != nil {
+		return err
+	}
+
+	if repoID == -1 {
+		return fmt.Errorf("repository %s has no backup in GitLab", prj.SourceRepository.Name)
+	}
+
+	slog.Info("Restoring project", "project_id", repoID, "target", target)
+	return prj.RestoreTo(ctx, target)
+}
+
+// forEachRepository paginates the source repositories of the groups that pass
+// the filters and calls fn with their GitLab name and the configuration each one
+// resolves to. A non-empty reason means the repository would be skipped by a sync.
+func forEachRepository(ctx context.Context, config *Configuration, srcs map[string]sources.Source, gitlab *GitLab, opts *Options, fn func(configRepo ConfigGroup, remote sources.SourceRepository, name string, cfg ConfigRepo, reason string)) {
+	for _, configRepo := range config.Groups {
+		if !opts.MatchGroup(configRepo) {
+			continue
+		}
+
+		source, configSource := srcs[configRepo.Source], config.GetSourceConfig(configRepo.Source)
+
+		// Missing groups are not created, their ID is -1
+		configRepo, err := resolveGroup(ctx, gitlab, configRepo, false)
+		if err != nil {
+			slog.Error("Resolving GitLab group", "source", configRepo.Source, "group", configRepo.Username, "error", err)
+			continue
+		}
+
+		remotes, err := listRepositories(ctx, source, configRepo.Username)
+		if err != nil {
+			slog.Error("Paginating repositories", "source", configRepo.Source, "group", configRepo.Username, "error", err)
+		}
+
+		// Collisions are detected over the complete listing, as in a sync
+		projects, err := listGroupProjects(ctx, gitlab, configRepo)
+		if err != nil {
+			slog.Error("Listing GitLab projects", "source", configRepo.Source, "group", configRepo.Username, "error", err)
+		}
+		names := projectNames(gitlab, configRepo, remotes, projects)
+
+		count := 1
+		for _, remote := range remotes {
+			if ctx.Err() != nil {
+				return
+			}
+
+			// Filtered out repositories still take their position, so skip applies as in a full run
+			var cfg ConfigRepo
+			var reason string
+			cfg, reason, count = resolveRemote(configSource, configRepo, remote, count)
+			if !opts.MatchRepository(remote.Name) {
+				continue
+			}
+
+			repoGroup, err := repositoryGroup(ctx, gitlab, configRepo, remote, false)
+			if err != nil {
+				slog.Error("Resolving GitLab group", "source", configRepo.Source, "group", configRepo.Username, "repo", remote.Name, "error", err)
+				continue
+			}
+
+			fn(repoGroup, remote, names[remote.URL], cfg, reason)
+		}
+	}
+}
@@ -2,27 +2,92 @@ package main
 
 import (
 	"fmt"
+	"log/slog"
 	"main/src/sources"
 	"main/src/utils"
+	"os"
+	"strings"
+	"time"
 )
 
 type Configuration struct {
-	Gitlab  ConfigGitLab  `json:"gitlab"`
-	Dufs    ConfigDufs    `json:"dufs"`
-	Config  ConfigRepo    `json:"config"`
-	Sources ConfigSources `json:"sources"`
-	Groups  []ConfigGroup `json:"groups"`
+	Gitlab          ConfigGitLab         `json:"gitlab"`
+	Dufs            ConfigDufs           `json:"dufs"`
+	Checkpoint      ConfigCheckpoint     `json:"checkpoint"`
+	Status          ConfigStatus         `json:"status"`
+	Cache           ConfigCache          `json:"cache"`
+	Schedule        *string              `json:"schedule"`
+	UpstreamDeleted *string              `json:"upstream_deleted"`
+	NameTemplate    *string              `json:"name_template"`
+	Server          ConfigServer         `json:"server"`
+	Webhook         ConfigWebhook        `json:"webhook"`
+	Log             ConfigLog            `json:"log"`
+	Report          ConfigReport         `json:"report"`
+	Notifications   []ConfigNotification `json:"notifications"`
+	Config          ConfigRepo           `json:"config"`
+	Sources         ConfigSources        `json:"sources"`
+	Groups          []ConfigGroup        `json:"groups"`
 }
 
 type ConfigGitLab struct {
-	URL   *string `json:"url"`
-	Token *string `json:"token"`
+	URL            *string `json:"url"`
+	Token          *string `json:"token"`
+	ImportTimeout  *string `json:"import_timeout"`
+	ImportFallback *bool   `json:"import_fallback"`
 }
 
 type ConfigDufs struct {
 	URL *string `json:"url"`
 }
 
+type ConfigCheckpoint struct {
+	Path *string `json:"path"`
+}
+
+type ConfigStatus struct {
+	Path *string `json:"path"`
+}
+
+type ConfigCache struct {
+	Path *string `json:"path"`
+}
+
+type ConfigServer struct {
+	Listen     *string `json:"listen"`
+	StaleAfter *string `json:"stale_after"`
+}
+
+type ConfigLog struct {
+	Level  *string `json:"level"`
+	Format *string `json:"format"`
+}
+
+type ConfigReport struct {
+	JSON  *string `json:"json"`
+	JUnit *string `json:"junit"`
+}
+
+type ConfigNotification struct {
+	Type        string      `json:"type"`
+	URL         *string     `json:"url"`
+	SMTP        *ConfigSMTP `json:"smtp"`
+	On          *string     `json:"on"`
+	MinFailures *int        `json:"min_failures"`
+}
+
+type ConfigSMTP struct {
+	Host     string   `json:"host"`
+	Port     *int     `json:"port"`
+	Username string   `json:"username"`
+	Password string   `json:"password"`
+	From     string   `json:"from"`
+	To       []string `json:"to"`
+}
+
+type ConfigWebhook struct {
+	Secret *string `json:"secret"`
+}
+
 // Sources configuration
 
 type ConfigSources struct {
@@ -43,8 +108,29 @@ type ConfigSourcesHuggingFace struct {
 // Repository configuration
 
 type ConfigRepo struct {
-	Wiki     ConfigRepoWiki     `json:"wiki"`
-	Releases ConfigRepoReleases `json:"releases"`
+	MirrorStrategy   *string               `json:"mirror_strategy"`
+	RewritePolicy    *string               `json:"rewrite_policy"`
+	RefDeletion      *string               `json:"ref_deletion"`
+	BranchProtection *string               `json:"branch_protection"`
+	ProjectSettings  ConfigProjectSettings `json:"project_settings"`
+	Avatar           ConfigRepoAvatar      `json:"avatar"`
+	MaxRefDeletions  *int                  `json:"max_ref_deletions"`
+	Wiki             ConfigRepoWiki        `json:"wiki"`
+	Releases         ConfigRepoReleases    `json:"releases"`
+}
+
+// ConfigProjectSettings holds the GitLab project attributes enforced on every sync, unset ones are left unchanged.
+type ConfigProjectSettings struct {
+	JobsEnabled              *bool   `json:"jobs_enabled"`
+	MergeRequestsAccessLevel *string `json:"merge_requests_access_level"`
+	IssuesEnabled            *bool   `json:"issues_enabled"`
+	PackagesEnabled          *bool   `json:"packages_enabled"`
+	Visibility               *string `json:"visibility"`
+	Archived                 *bool   `json:"archived"`
+}
+
+type ConfigRepoAvatar struct {
+	Exclude *bool `json:"exclude"`
 }
 
 type ConfigRepoWiki struct {
@@ -64,12 +150,17 @@ type ConfigRepoAssets struct {
 // Repositories configuration
 
 type ConfigGroup struct {
-	Source        string `json:"source"`
-	Username      string `json:"username"`
-	GitLabGroupID *int   `json:"gitlab_group_id"`
-
-	Skip   *int       `json:"skip"`
-	Config ConfigRepo `json:"config"`
+	Source        string  `json:"source"`
+	Username      string  `json:"username"`
+	GitLabGroupID *int    `json:"gitlab_group_id"`
+	GitLabPath    *string `json:"gitlab_path"`
+	TypeSubgroups *bool   `json:"type_subgroups"`
+
+	Skip            *int       `json:"skip"`
+	Schedule        *string    `json:"schedule"`
+	UpstreamDeleted *string    `json:"upstream_deleted"`
+	NameTemplate    *string    `json:"name_template"`
+	Config          ConfigRepo `json:"config"`
 
 	Repositories []ConfigRepositoryRepository `json:"repositories"`
 }
@@ -89,8 +180,9 @@ func (c *ConfigGroup) GetConfig(repoName string) *ConfigRepositoryRepository {
 type ConfigRepositoryRepository struct {
 	ConfigRepo
 
-	Name    string `json:"name"`
-	Exclude *bool  `json:"exclude"`
+	Name       string  `json:"name"`
+	Exclude    *bool   `json:"exclude"`
+	GitLabName *string `json:"gitlab_name"`
 }
 
 func (c *Configuration) PopulateDefault() {
@@ -98,7 +190,79 @@ func (c *Configuration) PopulateDefault() {
 		c.Gitlab.URL = utils.Pointer("https://gitlab.com/")
 	}
 
+	if c.Gitlab.ImportTimeout == nil {
+		c.Gitlab.ImportTimeout = utils.Pointer("30m")
+	}
+
+	if c.Gitlab.ImportFallback == nil {
+		c.Gitlab.ImportFallback = utils.Pointer(false)
+	}
+
+	if c.Log.Level == nil {
+		c.Log.Level = utils.Pointer("info")
+	}
+
+	if c.Log.Format == nil {
+		c.Log.Format = utils.Pointer("text")
+	}
+
+	if c.Server.StaleAfter == nil {
+		c.Server.StaleAfter = utils.Pointer("48h")
+	}
+
+	if c.Report.JSON == nil {
+		c.Report.JSON = utils.Pointer("/tmp/git-backup/report.json")
+	}
+
+	if c.Report.JUnit == nil {
+		c.Report.JUnit = utils.Pointer("/tmp/git-backup/report.xml")
+	}
+
+	for i := range c.Notifications {
+		notification := &c.Notifications[i]
+
+		if notification.On == nil {
+			notification.On = utils.Pointer(NotifyFailure)
+		}
+
+		if notification.MinFailures == nil {
+			notification.MinFailures = utils.Pointer(1)
+		}
+
+		if notification.SMTP != nil && notification.SMTP.Port == nil {
+			notification.SMTP.Port = utils.Pointer(587)
+		}
+	}
+
+	if c.Cache.Path == nil {
+		c.Cache.Path = utils.Pointer("/tmp/git-backup/cache")
+	}
+
+	if c.Checkpoint.Path == nil {
+		c.Checkpoint.Path = utils.Pointer("/tmp/git-backup/checkpoint.json")
+	}
+
+	if c.Status.Path == nil {
+		c.Status.Path = utils.Pointer("/tmp/git-backup/status.json")
+	}
+
+	if c.UpstreamDeleted == nil {
+		c.UpstreamDeleted = utils.Pointer(UpstreamDeletedReport)
+	}
+
+	if c.NameTemplate == nil {
+		c.NameTemplate = utils.Pointer("{name}")
+	}
+
 	c.Config.DefaultFrom(ConfigRepo{
+		MirrorStrategy:   utils.Pointer(MirrorPush),
+		RewritePolicy:    utils.Pointer(RewriteBackup),
+		RefDeletion:      utils.Pointer(RefDeletionKeep),
+		MaxRefDeletions:  utils.Pointer(10),
+		BranchProtection: utils.Pointer(ProtectionReprotect),
+		Avatar: ConfigRepoAvatar{
+			Exclude: utils.Pointer(true),
+		},
 		Wiki: ConfigRepoWiki{
 			Exclude: utils.Pointer(false),
 		},
@@ -130,6 +294,22 @@ func (c *Configuration) PopulateDefault() {
 			group.Skip = utils.Pointer(0)
 		}
 
+		if group.TypeSubgroups == nil {
+			group.TypeSubgroups = utils.Pointer(false)
+		}
+
+		if group.Schedule == nil {
+			group.Schedule = c.Schedule
+		}
+
+		if group.UpstreamDeleted == nil {
+			group.UpstreamDeleted = c.UpstreamDeleted
+		}
+
+		if group.NameTemplate == nil {
+			group.NameTemplate = c.NameTemplate
+		}
+
 		if group.Source == sources.GitHubID {
 			group.Config.DefaultFrom(c.Sources.GitHub.Config)
 		} else if group.Source == sources.HuggingFaceID {
@@ -149,6 +329,54 @@ func (c *Configuration) PopulateDefault() {
 }
 
 func (c *ConfigRepo) DefaultFrom(from ConfigRepo) {
+	if c.MirrorStrategy == nil {
+		c.MirrorStrategy = from.MirrorStrategy
+	}
+
+	if c.RewritePolicy == nil {
+		c.RewritePolicy = from.RewritePolicy
+	}
+
+	if c.RefDeletion == nil {
+		c.RefDeletion = from.RefDeletion
+	}
+
+	if c.MaxRefDeletions == nil {
+		c.MaxRefDeletions = from.MaxRefDeletions
+	}
+
+	if c.BranchProtection == nil {
+		c.BranchProtection = from.BranchProtection
+	}
+
+	if c.ProjectSettings.JobsEnabled == nil {
+		c.ProjectSettings.JobsEnabled = from.ProjectSettings.JobsEnabled
+	}
+
+	if c.ProjectSettings.MergeRequestsAccessLevel == nil {
+		c.ProjectSettings.MergeRequestsAccessLevel = from.ProjectSettings.MergeRequestsAccessLevel
+	}
+
+	if c.ProjectSettings.IssuesEnabled == nil {
+		c.ProjectSettings.IssuesEnabled = from.ProjectSettings.IssuesEnabled
+	}
+
+	if c.ProjectSettings.PackagesEnabled == nil {
+		c.ProjectSettings.PackagesEnabled = from.ProjectSettings.PackagesEnabled
+	}
+
+	if c.ProjectSettings.Visibility == nil {
+		c.ProjectSettings.Visibility = from.ProjectSettings.Visibility
+	}
+
+	if c.ProjectSettings.Archived == nil {
+		c.ProjectSettings.Archived = from.ProjectSettings.Archived
+	}
+
+	if c.Avatar.Exclude == nil {
+		c.Avatar.Exclude = from.Avatar.Exclude
+	}
+
 	if c.Wiki.Exclude == nil {
 		c.Wiki.Exclude = from.Wiki.Exclude
 	}
@@ -166,15 +394,145 @@ func (c *ConfigRepo) DefaultFrom(from ConfigRepo) {
 	}
 }
 
+func (c *ConfigRepo) Validate() error {
+	if *c.MirrorStrategy != MirrorPush && *c.MirrorStrategy != MirrorGitLabPull {
+		return fmt.Errorf("mirror_strategy must be push or gitlab_pull")
+	}
+
+	switch *c.RewritePolicy {
+	case RewriteBackup, RewriteRefuse, RewriteForce:
+	default:
+		return fmt.Errorf("rewrite_policy must be one of backup, refuse or force")
+	}
+
+	switch *c.RefDeletion {
+	case RefDeletionKeep, RefDeletionDelete, RefDeletionMove:
+	default:
+		return fmt.Errorf("ref_deletion must be one of keep, delete or move")
+	}
+
+	if *c.MaxRefDeletions < 1 {
+		return fmt.Errorf("max_ref_deletions must be at least 1")
+	}
+
+	// GitLab pulls the refs itself, the backup never sees them change
+	if *c.MirrorStrategy == MirrorGitLabPull && (*c.RewritePolicy != RewriteBackup || *c.RefDeletion != RefDeletionKeep) {
+		return fmt.Errorf("rewrite_policy and ref_deletion only apply to the push mirror_strategy")
+	}
+
+	if *c.BranchProtection != ProtectionReprotect && *c.BranchProtection != ProtectionUnprotect {
+		return fmt.Errorf("branch_protection must be reprotect or unprotect")
+	}
+
+	if level := c.ProjectSettings.MergeRequestsAccessLevel; level != nil {
+		switch *level {
+		case "disabled", "private", "enabled":
+		default:
+			return fmt.Errorf("project_settings.merge_requests_access_level must be one of disabled, private or enabled")
+		}
+	}
+
+	if visibility := c.ProjectSettings.Visibility; visibility != nil {
+		switch *visibility {
+		case "private", "internal", "public":
+		default:
+			return fmt.Errorf("project_settings.visibility must be one of private, internal or public")
+		}
+	}
+
+	return nil
+}
+
+// GetSourceConfig returns the configuration of the given source.
+func (c *Configuration) GetSourceConfig(source string) ConfigRepo {
+	switch source {
+	case sources.GitHubID:
+		return c.Sources.GitHub.Config
+	case sources.HuggingFaceID:
+		return c.Sources.HuggingFace.Config
+	}
+
+	return c.Config
+}
+
 func (c *Configuration) Validate() error {
 	if c.Dufs.URL == nil {
 		return fmt.Errorf("dufs url is required")
 	}
 
+	if timeout, err := time.ParseDuration(*c.Gitlab.ImportTimeout); err != nil || timeout <= 0 {
+		return fmt.Errorf("gitlab import_timeout must be a positive duration")
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(*c.Log.Level)); err != nil {
+		return fmt.Errorf("log level must be one of debug, info, warn or error")
+	}
+
+	if *c.Log.Format != "text" && *c.Log.Format != "json" {
+		return fmt.Errorf("log format must be text or json")
+	}
+
+	if _, err := time.ParseDuration(*c.Server.StaleAfter); err != nil {
+		return fmt.Errorf("server stale_after is not valid: %w", err)
+	}
+
+	if strings.HasSuffix(*c.Checkpoint.Path, "/") {
+		return fmt.Errorf("checkpoint path must be a file, not a directory")
+	}
+	if info, err := os.Stat(*c.Checkpoint.Path); err == nil && info.IsDir() {
+		return fmt.Errorf("checkpoint path %s is a directory", *c.Checkpoint.Path)
+	}
+
+	if strings.HasSuffix(*c.Status.Path, "/") {
+		return fmt.Errorf("status path must be a file, not a directory")
+	}
+	if info, err := os.Stat(*c.Status.Path); err == nil && info.IsDir() {
+		return fmt.Errorf("status path %s is a directory", *c.Status.Path)
+	}
+
+	if c.Webhook.Secret != nil && len(*c.Webhook.Secret) == 0 {
+		return fmt.Errorf("webhook secret must not be empty")
+	}
+
+	for i, notification := range c.Notifications {
+		switch notification.Type {
+		case NotificationSMTP:
+			if notification.SMTP == nil || len(notification.SMTP.Host) == 0 {
+				return fmt.Errorf("smtp host is required at notification %d", i)
+			}
+			if len(notification.SMTP.From) == 0 || len(notification.SMTP.To) == 0 {
+				return fmt.Errorf("smtp from and to are required at notification %d", i)
+			}
+			// Mails are sent in plain text or upgraded with STARTTLS, never over an implicit TLS connection
+			if *notification.SMTP.Port == 465 {
+				return fmt.Errorf("smtp port 465 (implicit TLS) is not supported at notification %d, use 587 (STARTTLS)", i)
+			}
+		case NotificationWebhook, NotificationSlack:
+			if notification.URL == nil || len(*notification.URL) == 0 {
+				return fmt.Errorf("url is required at notification %d", i)
+			}
+		default:
+			return fmt.Errorf("notification type %s is not valid at index %d", notification.Type, i)
+		}
+
+		if *notification.On != NotifyAlways && *notification.On != NotifyChanges && *notification.On != NotifyFailure {
+			return fmt.Errorf("notification on must be one of always, changes or failure at index %d", i)
+		}
+
+		if *notification.MinFailures < 1 {
+			return fmt.Errorf("notification min_failures must be at least 1 at index %d", i)
+		}
+	}
+
 	if c.Sources.GitHub == nil && c.Sources.HuggingFace == nil {
 		return fmt.Errorf("at least one source is required")
 	}
 
+	if err := c.Config.Validate(); err != nil {
+		return err
+	}
+
 	for i, repo := range c.Groups {
 		if repo.Source == sources.GitHubID {
 			if c.Sources.GitHub == nil {
@@ -192,14 +550,46 @@ func (c *Configuration) Validate() error {
 			return fmt.Errorf("username is required at index %d", i)
 		}
 
-		if repo.GitLabGroupID == nil || *repo.GitLabGroupID < 0 {
-			return fmt.Errorf("gitlab_group_id is required at index %d", i)
+		if repo.GitLabGroupID != nil && repo.GitLabPath != nil {
+			return fmt.Errorf("only one of gitlab_group_id and gitlab_path can be set at index %d", i)
+		}
+
+		if repo.GitLabPath != nil {
+			if fullPath := repo.GitLabFullPath(); len(fullPath) == 0 || strings.HasPrefix(fullPath, "/") || strings.HasSuffix(fullPath, "/") {
+				return fmt.Errorf("gitlab_path is not valid at index %d", i)
+			}
+		} else if repo.GitLabGroupID == nil || *repo.GitLabGroupID < 0 {
+			return fmt.Errorf("gitlab_group_id or gitlab_path is required at index %d", i)
+		}
+
+		if repo.Schedule != nil {
+			if _, err := utils.ParseCron(*repo.Schedule); err != nil {
+				return fmt.Errorf("schedule is not valid at index %d: %w", i, err)
+			}
+		}
+
+		switch *repo.UpstreamDeleted {
+		case UpstreamDeletedReport, UpstreamDeletedArchive, UpstreamDeletedTopic, UpstreamDeletedRename:
+		default:
+			return fmt.Errorf("upstream_deleted must be one of report, archive, topic or rename at index %d", i)
+		}
+
+		if !strings.Contains(*repo.NameTemplate, "{name}") {
+			return fmt.Errorf("name_template must contain {name} at index %d", i)
+		}
+
+		if err := repo.Config.Validate(); err != nil {
+			return fmt.Errorf("%w at index %d", err, i)
 		}
 
 		for j, repo2 := range repo.Repositories {
 			if len(repo2.Name) == 0 {
 				return fmt.Errorf("name is required at index %d.%d", i, j)
 			}
+
+			if err := repo2.ConfigRepo.Validate(); err != nil {
+				return fmt.Errorf("%w at index %d.%d", err, i, j)
+			}
 		}
 	}
 
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"log/slog"
+	"main/src/metrics"
+	"main/src/utils"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Daemon syncs the configured groups on their cron schedule until it is stopped.
+type Daemon struct {
+	Config  *Configuration
+	Options *Options
+
+	// Next holds the next run time of each scheduled group, keyed by group index
+	Next map[int]time.Time
+
+	mu      sync.Mutex
+	queue   chan WebhookJob
+	pending map[string]bool
+}
+
+func NewDaemon(config *Configuration, opts *Options) *Daemon {
+	d := &Daemon{
+		Options: opts,
+		queue:   make(chan WebhookJob, 256),
+		pending: make(map[string]bool),
+	}
+	d.SetConfig(config)
+	return d
+}
+
+func (d *Daemon) GetConfig() *Configuration {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.Config
+}
+
+// SetConfig replaces the configuration and recomputes the schedule of every group.
+func (d *Daemon) SetConfig(config *Configuration) {
+	d.mu.Lock()
+	d.Config = config
+	d.mu.Unlock()
+
+	d.Next = make(map[int]time.Time)
+
+	now := time.Now()
+	for i, group := range config.Groups {
+		if !d.Options.MatchGroup(group) {
+			continue
+		}
+
+		if group.Schedule == nil {
+			slog.Warn("Group has no schedule, it will not be synced", "source", group.Source, "group", group.Username)
+			continue
+		}
+
+		// Already validated with the configuration
+		cron, _ := utils.ParseCron(*group.Schedule)
+		d.Next[i] = cron.Next(now)
+		slog.Info("Scheduled group", "source", group.Source, "group", group.Username, "next_run", d.Next[i])
+	}
+}
+
+// NextRun returns the earliest scheduled time of all groups.
+func (d *Daemon) NextRun() (time.Time, bool) {
+	var next time.Time
+	for _, t := range d.Next {
+		// Expressions that never match (e.g. "0 0 31 2 *") have no next time
+		if t.IsZero() {
+			continue
+		}
+
+		if next.IsZero() || t.Before(next) {
+			next = t
+		}
+	}
+
+	return next, !next.IsZero()
+}
+
+// RunDue syncs every group whose scheduled time has passed and schedules its next run.
+func (d *Daemon) RunDue(ctx context.Context) {
+	now := time.Now()
+
+	due := make(map[int]bool)
+	for i, t := range d.Next {
+		if !t.IsZero() && !t.After(now) {
+			due[i] = true
+		}
+	}
+
+	if len(due) == 0 {
+		return
+	}
+
+	// The scheduled run waits for the run in progress, such as a sync command, instead of being dropped
+	unlock, err := utils.Lock(LockPath)
+	if errors.Is(err, utils.ErrLocked) {
+		slog.Info("Waiting for the run in progress to finish")
+		unlock, err = utils.WaitLock(ctx, LockPath, lockRetryDelay)
+	}
+
+	if errors.Is(err, context.Canceled) {
+		return
+	} else if err != nil {
+		failRun(d.Config, "schedule", err)
+	} else {
+		checkpoint := NewCheckpoint(*d.Config.Checkpoint.Path)
+		_, err = syncGroups(ctx, d.Config, d.Options, checkpoint, "schedule", func(i int, group ConfigGroup) bool {
+			return due[i]
+		})
+		unlock()
+	}
+
+	if errors.Is(err, context.Canceled) {
+		slog.Warn("Scheduled run interrupted")
+	} else if err != nil {
+		slog.Error("Scheduled run failed", "error", err)
+	}
+
+	for i := range due {
+		cron, _ := utils.ParseCron(*d.Config.Groups[i].Schedule)
+		d.Next[i] = cron.Next(time.Now())
+	}
+}
+
+// Handler returns the HTTP handler served by the daemon.
+func (d *Daemon) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/webhook", d.HandleWebhook)
+	mux.Handle("/metrics", metrics.Handler())
+
+	// Already validated with the configuration
+	staleAfter, _ := time.ParseDuration(*d.Config.Server.StaleAfter)
+	RegisterStatusHandlers(mux, staleAfter)
+
+	return mux
+}
+
+// Run syncs the groups on their schedule and serves the HTTP API until SIGINT or
+// SIGTERM, which aborts the current sync and waits for it to clean up.
+func (d *Daemon) Run() {
+	ctx, stop := signalContext()
+	defer stop()
+
+	var workers sync.WaitGroup
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		d.RunQueue(ctx)
+	}()
+
+	var server *http.Server
+	if listen := d.Config.Server.Listen; listen != nil {
+		slog.Info("Listening", "address", *listen)
+		server = &http.Server{Addr: *listen, Handler: d.Handler()}
+		go func() {
+			if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Fatal(err)
+			}
+		}()
+	}
+
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+
+	for {
+		next, ok := d.NextRun()
+
+		// Without any scheduled group, only wait for a reload or a stop
+		var timer <-chan time.Time
+		if ok {
+			slog.Info("Waiting for next run", "next_run", next)
+			timer = time.After(time.Until(next))
+		} else {
+			slog.Warn("No group is scheduled, waiting for a configuration reload")
+		}
+
+		select {
+		case <-timer:
+			d.RunDue(ctx)
+		case <-reload:
+			slog.Info("Reloading configuration")
+			config, err := LoadConfiguration(d.Options.ConfigPath)
+			if err != nil {
+				slog.Error("Keeping the previous configuration", "error", err)
+				continue
+			}
+			SetupLogger(config.Log)
+			d.SetConfig(config)
+		case <-ctx.Done():
+			slog.Info("Stopping")
+
+			if server != nil {
+				shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+				if err := server.Shutdown(shutdownCtx); err != nil {
+					slog.Error("Stopping HTTP server", "error", err)
+				}
+				cancel()
+			}
+
+			workers.Wait()
+			return
+		}
+	}
+}
+
+func runServe(args []string) {
+	flags, opts := NewFlagSet("serve")
+	parseFlags(flags, opts, args)
+
+	config := loadConfiguration(opts.ConfigPath)
+
+	if err := Status.Load(*config.Status.Path); err != nil {
+		slog.Error("Loading status", "error", err)
+	}
+
+	NewDaemon(config, opts).Run()
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"time"
+)
+
+var dashboardTemplate = template.Must(template.New("dashboard").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Git Backup</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; }
+.failed { color: #b00; }
+.stale { color: #a60; }
+</style>
+</head>
+<body>
+<h1>Git Backup</h1>
+
+<h2>Runs</h2>
+{{if .Current}}<p>Run #{{.Current.ID}} ({{.Current.Trigger}}) in progress since {{.Current.StartedAt.Format "2006-01-02 15:04:05"}}</p>{{end}}
+<table>
+<tr><th>#</th><th>Trigger</th><th>Started</th><th>Finished</th><th>Synced</th><th>Failed</th><th>Skipped</th></tr>
+{{range .Runs}}<tr><td>{{.ID}}</td><td>{{.Trigger}}</td><td>{{.StartedAt.Format "2006-01-02 15:04:05"}}</td><td>{{if .FinishedAt}}{{.FinishedAt.Format "2006-01-02 15:04:05"}}{{end}}</td><td>{{.Synced}}</td><td class="{{if .Failed}}failed{{end}}">{{.Failed}}</td><td>{{.Skipped}}</td></tr>
+{{else}}<tr><td colspan="7">No runs yet</td></tr>
+{{end}}</table>
+
+<h2>Failing or stale repositories</h2>
+<p>Repositories that failed or have not been synced successfully in the last {{.StaleAfter}}.</p>
+<table>
+<tr><th>Repository</th><th>Status</th><th>Last success</th><th>Duration</th><th>Error</th></tr>
+{{range .Problems}}<tr><td>{{.Source}}/{{.Username}}/{{.Name}}</td><td class="{{if eq .Status "failed"}}failed{{else}}stale{{end}}">{{.Status}}</td><td>{{if .LastSuccess}}{{.LastSuccess.Format "2006-01-02 15:04:05"}}{{else}}never{{end}}</td><td>{{printf "%.1fs" .Duration}}</td><td>{{.Error}}</td></tr>
+{{else}}<tr><td colspan="5">All repositories are up to date</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// RegisterStatusHandlers adds the status API and the dashboard to the mux.
+func RegisterStatusHandlers(mux *http.ServeMux, staleAfter time.Duration) {
+	mux.HandleFunc("/api/runs", func(w http.ResponseWriter, r *http.Request) {
+		current, runs, _ := Status.Snapshot()
+		writeJSON(w, map[string]any{
+			"current": current,
+			"runs":    runs,
+		})
+	})
+
+	mux.HandleFunc("/api/repositories", func(w http.ResponseWriter, r *http.Request) {
+		_, _, repos := Status.Snapshot()
+
+		// Optionally filter by status, "stale" selects the repositories without a recent success
+		filter := r.URL.Query().Get("status")
+		if len(filter) > 0 {
+			repos = filterRepos(repos, func(repo RepoStatus) bool {
+				if filter == "stale" {
+					return repo.IsStale(staleAfter, time.Now())
+				}
+				return repo.Status == filter
+			})
+		}
+
+		writeJSON(w, repos)
+	})
+
+	mux.HandleFunc("/api/errors", func(w http.ResponseWriter, r *http.Request) {
+		_, _, repos := Status.Snapshot()
+		writeJSON(w, filterRepos(repos, func(repo RepoStatus) bool {
+			return repo.Status == RepoStatusFailed
+		}))
+	})
+
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+
+		current, runs, repos := Status.Snapshot()
+		now := time.Now()
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		err := dashboardTemplate.Execute(w, map[string]any{
+			"Current":    current,
+			"Runs":       runs,
+			"StaleAfter": staleAfter.String(),
+			"Problems": filterRepos(repos, func(repo RepoStatus) bool {
+				return repo.Status == RepoStatusFailed || repo.IsStale(staleAfter, now)
+			}),
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+func filterRepos(repos []RepoStatus, keep func(repo RepoStatus) bool) []RepoStatus {
+	result := make([]RepoStatus, 0)
+	for _, repo := range repos {
+		if keep(repo) {
+			result = append(result, repo)
+		}
+	}
+
+	return result
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// RefDeletionKeep leaves the branches and tags deleted upstream in GitLab
+	RefDeletionKeep = "keep"
+
+	// RefDeletionDelete deletes the branches and tags deleted upstream from GitLab
+	RefDeletionDelete = "delete"
+
+	// RefDeletionMove moves the branches and tags deleted upstream to refs/deleted/<timestamp>/ in GitLab
+	RefDeletionMove = "move"
+)
+
+// StaleRefs returns the GitLab branches and tags missing from the source refs. FetchDestination must be called first.
+func (g *Project) StaleRefs(sourceRefs map[string]string) ([]plumbing.ReferenceName, error) {
+	refs, err := g.Repo.References()
+	if err != nil {
+		return nil, err
+	}
+
+	stale := make([]plumbing.ReferenceName, 0)
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		if !strings.HasPrefix(ref.Name().String(), destinationPrefix) {
+			return nil
+		}
+
+		name := "refs/" + strings.TrimPrefix(ref.Name().String(), destinationPrefix)
+		if _, ok := sourceRefs[name]; !ok {
+			stale = append(stale, plumbing.ReferenceName(name))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(stale, func(i, j int) bool { return stale[i] < stale[j] })
+	return stale, nil
+}
+
+// DeleteRefs deletes refs from GitLab, after copying them to refs/deleted/<timestamp>/ when move is set.
+func (g *Project) DeleteRefs(ctx context.Context, refs []plumbing.ReferenceName, move bool, timestamp string) error {
+	if move {
+		refSpecs := make([]config.RefSpec, 0, len(refs))
+		for _, ref := range refs {
+			moved := fmt.Sprintf("refs/deleted/%s/%s", timestamp, strings.TrimPrefix(ref.String(), "refs/"))
+			refSpecs = append(refSpecs, config.RefSpec(destinationRef(ref).String()+":"+moved))
+		}
+
+		err := g.Repo.PushContext(ctx, &git.PushOptions{
+			RemoteName: "gitlab",
+			RefSpecs:   refSpecs,
+		})
+		if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+			return err
+		}
+	}
+
+	refSpecs := make([]config.RefSpec, 0, len(refs))
+	for _, ref := range refs {
+		refSpecs = append(refSpecs, config.RefSpec(":"+ref.String()))
+	}
+
+	err := g.Repo.PushContext(ctx, &git.PushOptions{
+		RemoteName: "gitlab",
+		RefSpecs:   refSpecs,
+	})
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return err
+	}
+
+	return nil
+}
+
+// syncDeletions applies the ref_deletion mode to the GitLab branches and tags that no longer exist in the source.
+func syncDeletions(ctx context.Context, prj *Project, log *slog.Logger) error {
+	mode := *prj.Config.RefDeletion
+	if mode == RefDeletionKeep {
+		return nil
+	}
+
+	// The clone only has the default branch locally, so the source is listed instead
+	sourceRefs, err := ListRefs(ctx, prj.SourceRepository.URL)
+	if err != nil {
+		return fmt.Errorf("listing source refs: %w", err)
+	}
+
+	if err := prj.FetchDestination(ctx); err != nil {
+		return fmt.Errorf("fetching GitLab refs: %w", err)
+	}
+
+	stale, err := prj.StaleRefs(sourceRefs)
+	if err != nil {
+		return fmt.Errorf("comparing with GitLab refs: %w", err)
+	}
+
+	if len(stale) == 0 {
+		return nil
+	}
+
+	// A source listing gone wrong must not empty the backup
+	if limit := *prj.Config.MaxRefDeletions; len(stale) > limit {
+		return fmt.Errorf("refusing to delete %d refs deleted upstream, more than max_ref_deletions (%d)", len(stale), limit)
+	}
+
+	timestamp := time.Now().UTC().Format("20060102T150405Z")
+	for _, ref := range stale {
+		log.Warn("Deleting ref deleted upstream", "ref", ref.String(), "mode", mode)
+	}
+
+	if err := prj.DeleteRefs(ctx, stale, mode == RefDeletionMove, timestamp); err != nil {
+		return fmt.Errorf("deleting refs deleted upstream: %w", err)
+	}
+	prj.Result.RefsDeleted += len(stale)
+
+	return nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"github.com/go-git/go-git/v5/plumbing"
+	"slices"
+	"testing"
+)
+
+func TestStaleRefs(t *testing.T) {
+	repo, commit := testRepository(t)
+	hash := commit(plumbing.ZeroHash, "first")
+
+	for _, name := range []string{
+		destinationPrefix + "heads/main",
+		destinationPrefix + "heads/removed",
+		destinationPrefix + "tags/v1",
+		destinationPrefix + "tags/v0",
+		"refs/heads/local",
+		"refs/backup/20240115T100000Z/heads/main",
+	} {
+		setRef(t, repo, name, hash)
+	}
+
+	tests := []struct {
+		desc       string
+		sourceRefs map[string]string
+		want       []plumbing.ReferenceName
+	}{
+		{"all present", map[string]string{
+			"refs/heads/main":    hash.String(),
+			"refs/heads/removed": hash.String(),
+			"refs/tags/v1":       hash.String(),
+			"refs/tags/v0":       hash.String(),
+		}, []plumbing.ReferenceName{}},
+		{"deleted upstream", map[string]string{
+			"refs/heads/main": hash.String(),
+			"refs/tags/v1":    hash.String(),
+			"refs/heads/new":  hash.String(),
+		}, []plumbing.ReferenceName{"refs/heads/removed", "refs/tags/v0"}},
+		{"empty source", map[string]string{}, []plumbing.ReferenceName{
+			"refs/heads/main", "refs/heads/removed", "refs/tags/v0", "refs/tags/v1",
+		}},
+	}
+
+	prj := &Project{Repo: repo}
+	for _, tt := range tests {
+		stale, err := prj.StaleRefs(tt.sourceRefs)
+		if err != nil {
+			t.Fatalf("%s: StaleRefs: %v", tt.desc, err)
+		}
+
+		if !slices.Equal(stale, tt.want) {
+			t.Errorf("%s: StaleRefs = %v, want %v", tt.desc, stale, tt.want)
+		}
+	}
+}
@@ -1,8 +1,11 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"main/src/metrics"
+	"main/src/utils"
 	"net/http"
 	"net/url"
 	"os"
@@ -16,20 +19,27 @@ func NewDufs(url url.URL) *Dufs {
 	return &Dufs{url}
 }
 
-func (d *Dufs) UploadFIle(srcPath, dstPath string) error {
-	file, err := os.Open(srcPath)
+func (d *Dufs) UploadFIle(ctx context.Context, srcPath, dstPath string) error {
+	info, err := os.Stat(srcPath)
 	if err != nil {
 		return fmt.Errorf("error opening file: %w", err)
 	}
-	defer file.Close()
 
-	request, err := http.NewRequest(http.MethodPut, d.URL.JoinPath(dstPath).String(), file)
+	request, err := http.NewRequestWithContext(ctx, http.MethodPut, d.URL.JoinPath(dstPath).String(), nil)
 	if err != nil {
 		return fmt.Errorf("error creating request: %w", err)
 	}
 
-	client := &http.Client{}
-	response, err := client.Do(request)
+	// The file is reopened on every attempt, as the previous one consumed it
+	request.GetBody = func() (io.ReadCloser, error) {
+		return os.Open(srcPath)
+	}
+	if request.Body, err = request.GetBody(); err != nil {
+		return fmt.Errorf("error opening file: %w", err)
+	}
+	request.ContentLength = info.Size()
+
+	response, err := utils.DoRequest(request)
 	if err != nil {
 		return fmt.Errorf("error performing request: %w", err)
 	}
@@ -40,17 +50,18 @@ func (d *Dufs) UploadFIle(srcPath, dstPath string) error {
 		return fmt.Errorf("received non-success status code: %d, body: %s", response.StatusCode, responseBody)
 	}
 
+	metrics.UploadedBytes.Add(float64(info.Size()))
+
 	return nil
 }
 
-func (d *Dufs) DeletePath(path string) error {
-	req, err := http.NewRequest(http.MethodDelete, d.URL.JoinPath(path).String(), nil)
+func (d *Dufs) DeletePath(ctx context.Context, path string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, d.URL.JoinPath(path).String(), nil)
 	if err != nil {
 		return fmt.Errorf("error creating request: %w", err)
 	}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := utils.DoRequest(req)
 	if err != nil {
 		return fmt.Errorf("error performing request: %w", err)
 	}
@@ -2,22 +2,42 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"io"
+	"main/src/metrics"
+	"main/src/utils"
 	"net/http"
 	"net/url"
 	"regexp"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
 type GitLab struct {
 	URL      url.URL
 	APIToken string
+
+	// ImportTimeout bounds the wait for the importer of a new project
+	ImportTimeout time.Duration
+
+	// ImportFallback clones and pushes the repository when the importer fails
+	ImportFallback bool
+
+	// PollInterval is how often the import and pull mirror statuses are checked
+	PollInterval time.Duration
+
+	// groups caches the IDs of the groups resolved from their full path
+	groups   map[string]int
+	groupsMu sync.Mutex
 }
 
 func NewGitLab(url url.URL, apiToken string) *GitLab {
 	return &GitLab{
-		URL:      url,
-		APIToken: apiToken,
+		URL:          url,
+		APIToken:     apiToken,
+		PollInterval: 5 * time.Second,
 	}
 }
 
@@ -26,7 +46,17 @@ type Response struct {
 	Body   []byte
 }
 
-func (g *GitLab) Request(method, path string, data []byte) (*Response, error) {
+func (g *GitLab) Request(ctx context.Context, method, path string, data []byte) (*Response, error) {
+	contentType := ""
+	if data != nil {
+		contentType = "application/x-www-form-urlencoded"
+	}
+
+	return g.RequestBody(ctx, method, path, contentType, data)
+}
+
+// RequestBody sends data with the given content type, for the requests that are not forms.
+func (g *GitLab) RequestBody(ctx context.Context, method, path, contentType string, data []byte) (*Response, error) {
 	pathQuery := strings.Split(path, "?")
 
 	urlPath := g.URL.JoinPath(pathQuery[0])
@@ -38,10 +68,12 @@ func (g *GitLab) Request(method, path string, data []byte) (*Response, error) {
 	var err error
 
 	if data != nil {
-		req, err = http.NewRequest(method, urlPath.String(), bytes.NewBuffer(data))
-		req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+		req, err = http.NewRequestWithContext(ctx, method, urlPath.String(), bytes.NewBuffer(data))
+		if err == nil {
+			req.Header.Add("Content-Type", contentType)
+		}
 	} else {
-		req, err = http.NewRequest(method, urlPath.String(), nil)
+		req, err = http.NewRequestWithContext(ctx, method, urlPath.String(), nil)
 	}
 
 	if err != nil {
@@ -51,13 +83,19 @@ func (g *GitLab) Request(method, path string, data []byte) (*Response, error) {
 	req.Header.Add("Private-Token", g.APIToken)
 	req.Header.Add("Accept", "*/*")
 
-	client := &http.Client{}
-	res, err := client.Do(req)
+	start := time.Now()
+
+	res, err := utils.DoRequest(req)
 	if err != nil {
+		metrics.GitLabRequests.Inc(method, "error")
 		return nil, err
 	}
 	defer res.Body.Close()
 
+	code := strconv.Itoa(res.StatusCode)
+	metrics.GitLabRequests.Inc(method, code)
+	metrics.GitLabRequestDuration.Observe(time.Since(start).Seconds(), method, code)
+
 	body, err := io.ReadAll(res.Body)
 	if err != nil {
 		return nil, err
@@ -94,8 +132,9 @@ func (g *GitLab) IsReservedName(name string) bool {
 		"wikis",
 	}
 
+	// GitLab routes are case insensitive
 	for _, rn := range rn {
-		if name == rn {
+		if strings.EqualFold(name, rn) {
 			return true
 		}
 	}
@@ -109,8 +148,8 @@ func (g *GitLab) IsValidName(name string) bool {
 		return false
 	}
 
-	// Rule 2: should not start with '-'
-	if strings.HasPrefix(name, "-") || strings.HasSuffix(name, "-") {
+	// Rule 2: should start with a letter, a digit or '_', and not end with '-'
+	if strings.HasPrefix(name, "-") || strings.HasPrefix(name, ".") || strings.HasSuffix(name, "-") {
 		return false
 	}
 
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+// testGitLab returns a GitLab client of a test server answering with handler.
+func testGitLab(t *testing.T, handler http.HandlerFunc) *GitLab {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return NewGitLab(*serverURL, "token")
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"main/src/sources"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+)
+
+type GitLabGroup struct {
+	ID       int    `json:"id"`
+	FullPath string `json:"full_path"`
+}
+
+// GetGroup returns the group with the given ID or full path, or nil when it does not exist.
+func (g *GitLab) GetGroup(ctx context.Context, idOrPath string) (*GitLabGroup, error) {
+	body, err := g.Request(ctx, http.MethodGet, "/api/v4/groups/"+url.PathEscape(idOrPath), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if body.Status == http.StatusNotFound {
+		return nil, nil
+	}
+
+	if body.Status != http.StatusOK {
+		return nil, fmt.Errorf("retrieving group %s: status %d", idOrPath, body.Status)
+	}
+
+	var group GitLabGroup
+	if err := json.Unmarshal(body.Body, &group); err != nil {
+		return nil, err
+	}
+
+	return &group, nil
+}
+
+// CreateGroup creates a private group, in the parent group unless parentID is 0.
+func (g *GitLab) CreateGroup(ctx context.Context, name string, parentID int) (*GitLabGroup, error) {
+	data := url.Values{}
+	data.Add("name", name)
+	data.Add("path", name)
+	data.Add("visibility", "private")
+	if parentID != 0 {
+		data.Add("parent_id", strconv.Itoa(parentID))
+	}
+
+	body, err := g.Request(ctx, http.MethodPost, "/api/v4/groups", []byte(data.Encode()))
+	if err != nil {
+		return nil, err
+	}
+
+	if body.Status != http.StatusCreated {
+		return nil, fmt.Errorf("creating group %s: status %d: %s", name, body.Status, body.Body)
+	}
+
+	var group GitLabGroup
+	if err := json.Unmarshal(body.Body, &group); err != nil {
+		return nil, err
+	}
+
+	return &group, nil
+}
+
+// ResolveGroup returns the ID of the group at the full path, or -1 when it does not exist.
+// With create, the group and its missing parents are created instead.
+func (g *GitLab) ResolveGroup(ctx context.Context, fullPath string, create bool) (int, error) {
+	g.groupsMu.Lock()
+	id, ok := g.groups[fullPath]
+	g.groupsMu.Unlock()
+	if ok {
+		return id, nil
+	}
+
+	group, err := g.GetGroup(ctx, fullPath)
+	if err != nil {
+		return -1, err
+	}
+
+	if group == nil {
+		if !create {
+			return -1, nil
+		}
+
+		parentID := 0
+		parent, name := path.Split(fullPath)
+		if parent = strings.TrimSuffix(parent, "/"); len(parent) > 0 {
+			if parentID, err = g.ResolveGroup(ctx, parent, true); err != nil {
+				return -1, err
+			}
+		}
+
+		if group, err = g.CreateGroup(ctx, name, parentID); err != nil {
+			return -1, err
+		}
+	}
+
+	g.groupsMu.Lock()
+	if g.groups == nil {
+		g.groups = make(map[string]int)
+	}
+	g.groups[fullPath] = group.ID
+	g.groupsMu.Unlock()
+
+	return group.ID, nil
+}
+
+// GitLabFullPath returns the gitlab_path of the group with its placeholders replaced.
+func (c *ConfigGroup) GitLabFullPath() string {
+	return strings.NewReplacer("{source}", c.Source, "{username}", c.Username).Replace(*c.GitLabPath)
+}
+
+// resolveGroup returns the configured group with the ID of its GitLab group, found from its gitlab_path
+// when it has no gitlab_group_id. The ID is -1 when the group does not exist and create is not set.
+func resolveGroup(ctx context.Context, gitlab *GitLab, groupCfg ConfigGroup, create bool) (ConfigGroup, error) {
+	if groupCfg.GitLabGroupID != nil {
+		// The full path is needed to find the type subgroups
+		if *groupCfg.TypeSubgroups && groupCfg.GitLabPath == nil {
+			group, err := gitlab.GetGroup(ctx, strconv.Itoa(*groupCfg.GitLabGroupID))
+			if err != nil {
+				return groupCfg, err
+			}
+			if group == nil {
+				return groupCfg, fmt.Errorf("GitLab group %d does not exist", *groupCfg.GitLabGroupID)
+			}
+			groupCfg.GitLabPath = &group.FullPath
+		}
+
+		return groupCfg, nil
+	}
+
+	fullPath := groupCfg.GitLabFullPath()
+	id, err := gitlab.ResolveGroup(ctx, fullPath, create)
+	if err != nil {
+		return groupCfg, fmt.Errorf("resolving GitLab group %s: %w", fullPath, err)
+	}
+
+	groupCfg.GitLabGroupID = &id
+	groupCfg.GitLabPath = &fullPath
+	return groupCfg, nil
+}
+
+// repositoryGroup returns the configured group with the ID of the GitLab group of the repository, which
+// is the subgroup of its type (models or datasets) with type_subgroups. resolveGroup must be called first.
+func repositoryGroup(ctx context.Context, gitlab *GitLab, groupCfg ConfigGroup, remote sources.SourceRepository, create bool) (ConfigGroup, error) {
+	if !*groupCfg.TypeSubgroups || len(remote.Type) == 0 {
+		return groupCfg, nil
+	}
+
+	fullPath := groupCfg.GitLabFullPath() + "/" + remote.Type + "s"
+	id, err := gitlab.ResolveGroup(ctx, fullPath, create)
+	if err != nil {
+		return groupCfg, fmt.Errorf("resolving GitLab group %s: %w", fullPath, err)
+	}
+
+	groupCfg.GitLabGroupID = &id
+	return groupCfg, nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestResolveGroup(t *testing.T) {
+	ids := map[string]int{"backup": 1}
+	paths := map[int]string{1: "backup"}
+
+	requests := make([]string, 0)
+	gitlab := testGitLab(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			fullPath, _ := url.PathUnescape(strings.TrimPrefix(r.URL.EscapedPath(), "/api/v4/groups/"))
+			requests = append(requests, "GET "+fullPath)
+
+			id, ok := ids[fullPath]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(GitLabGroup{ID: id, FullPath: fullPath})
+		case http.MethodPost:
+			fullPath := r.FormValue("path")
+			if parentID, _ := strconv.Atoi(r.FormValue("parent_id")); parentID != 0 {
+				fullPath = paths[parentID] + "/" + fullPath
+			}
+			requests = append(requests, "POST "+fullPath)
+
+			id := len(ids) + 1
+			ids[fullPath], paths[id] = id, fullPath
+			w.WriteHeader(http.StatusCreated)
+			_ = json.NewEncoder(w).Encode(GitLabGroup{ID: id, FullPath: fullPath})
+		}
+	})
+
+	tests := []struct {
+		desc     string
+		fullPath string
+		create   bool
+		want     int
+		requests []string
+	}{
+		{"missing", "backup/github/alice", false, -1, []string{"GET backup/github/alice"}},
+		{"missing parents created", "backup/github/alice", true, 3, []string{
+			"GET backup/github/alice",
+			"GET backup/github",
+			"GET backup",
+			"POST backup/github",
+			"POST backup/github/alice",
+		}},
+		{"cached", "backup/github/alice", true, 3, []string{}},
+		{"cached parent", "backup/github/bob", true, 4, []string{"GET backup/github/bob", "POST backup/github/bob"}},
+		{"parent cached", "backup", false, 1, []string{}},
+	}
+
+	for _, tt := range tests {
+		requests = requests[:0]
+
+		id, err := gitlab.ResolveGroup(context.Background(), tt.fullPath, tt.create)
+		if err != nil {
+			t.Errorf("%s: %v", tt.desc, err)
+			continue
+		}
+
+		if id != tt.want {
+			t.Errorf("%s: id = %d, want %d", tt.desc, id, tt.want)
+		}
+		if !slices.Equal(requests, tt.requests) {
+			t.Errorf("%s: requests = %v, want %v", tt.desc, requests, tt.requests)
+		}
+	}
+}
@@ -4,63 +4,92 @@ import (
 	"fmt"
 	"github.com/yosuke-furukawa/json5/encoding/json5"
 	"log"
+	"log/slog"
 	"main/src/sources"
 	"main/src/utils"
 	"net/url"
+	"os"
+	"time"
 )
 
 func main() {
-	file, err := utils.OpenConfigFile()
+	RunCLI(os.Args[1:])
+}
+
+func loadConfiguration(path string) *Configuration {
+	config, err := LoadConfiguration(path)
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	SetupLogger(config.Log)
+	return config
+}
+
+// SetupLogger makes the default logger use the configured level and format.
+func SetupLogger(cfg ConfigLog) {
+	// Both are already validated with the configuration
+	var level slog.Level
+	_ = level.UnmarshalText([]byte(*cfg.Level))
+
+	options := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler
+	if *cfg.Format == "json" {
+		handler = slog.NewJSONHandler(os.Stderr, options)
+	} else {
+		handler = slog.NewTextHandler(os.Stderr, options)
+	}
+
+	slog.SetDefault(slog.New(handler))
+}
+
+func LoadConfiguration(path string) (*Configuration, error) {
+	file, err := utils.OpenConfigFile(path)
+	if err != nil {
+		return nil, err
+	}
+
 	var config Configuration
 	err = json5.Unmarshal(file, &config)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
 	config.PopulateDefault()
 
 	if err := config.Validate(); err != nil {
-		log.Fatal("Configuration error:", err)
+		return nil, fmt.Errorf("configuration error: %w", err)
 	}
 
-	gitlabUrl, _ := url.Parse(*config.Gitlab.URL)
-	gitlab := NewGitLab(*gitlabUrl, *config.Gitlab.Token)
+	return &config, nil
+}
 
-	dufsUrl, _ := url.Parse(*config.Dufs.URL)
-	dufs := NewDufs(*dufsUrl)
+func newSources(config *Configuration) map[string]sources.Source {
+	srcs := make(map[string]sources.Source)
+	cache := utils.NewHTTPCache(*config.Cache.Path)
 
-	var github *sources.Github
 	if config.Sources.GitHub != nil {
-		github = sources.NewGithub(config.Sources.GitHub.Token)
+		srcs[sources.GitHubID] = sources.NewGithub(config.Sources.GitHub.Token, cache)
 	}
 
-	var huggingFaceModel *sources.HuggingFace
 	if config.Sources.HuggingFace != nil {
-		huggingFaceModel = sources.NewHuggingFace(config.Sources.HuggingFace.Token)
+		srcs[sources.HuggingFaceID] = sources.NewHuggingFace(config.Sources.HuggingFace.Token, cache)
 	}
 
-	for _, configRepo := range config.Groups {
-		var source sources.Source
-		var configSource ConfigRepo
-
-		if configRepo.Source == sources.GitHubID {
-			source = github
-			configSource = config.Sources.GitHub.Config
-		} else if configRepo.Source == sources.HuggingFaceID {
-			source = huggingFaceModel
-			configSource = config.Sources.HuggingFace.Config
-		} else {
-			log.Fatalf("source %s not found", configRepo.Source)
-		}
-
-		fmt.Println("\n================================================")
-		fmt.Printf("Evaluating group %s from %s\n", configRepo.Username, configRepo.Source)
-		fmt.Println("================================================")
-
-		SyncUser(gitlab, dufs, configSource, configRepo, source)
-	}
+	return srcs
+}
+
+func newDestinations(config *Configuration) (*GitLab, *Dufs) {
+	gitlabUrl, _ := url.Parse(*config.Gitlab.URL)
+	gitlab := NewGitLab(*gitlabUrl, *config.Gitlab.Token)
+
+	// Already validated with the configuration
+	gitlab.ImportTimeout, _ = time.ParseDuration(*config.Gitlab.ImportTimeout)
+	gitlab.ImportFallback = *config.Gitlab.ImportFallback
+
+	dufsUrl, _ := url.Parse(*config.Dufs.URL)
+	dufs := NewDufs(*dufsUrl)
+
+	return gitlab, dufs
 }
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"main/src/sources"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// projectDescription returns the GitLab description of a source repository. GitLab projects
+// have no homepage, so it is appended to the description.
+func projectDescription(remote sources.SourceRepository) *string {
+	description := ""
+	if remote.Description != nil {
+		description = *remote.Description
+	}
+
+	if remote.Homepage != nil && len(*remote.Homepage) > 0 {
+		if len(description) > 0 {
+			description += "\n\n"
+		}
+		description += "Homepage: " + *remote.Homepage
+	}
+
+	if remote.Description == nil && len(description) == 0 {
+		return nil
+	}
+
+	return &description
+}
+
+// metadataChanges returns the attributes of the project that differ from the metadata of the source repository.
+func metadataChanges(remote sources.SourceRepository, project *ProjectGitLab) url.Values {
+	data := url.Values{}
+
+	if description := projectDescription(remote); description != nil && *description != project.Description {
+		data.Add("description", *description)
+	}
+
+	if remote.Topics != nil && !sameTopics(remote.Topics, project.Topics) {
+		if len(remote.Topics) == 0 {
+			data.Add("topics", "")
+		}
+		for _, topic := range remote.Topics {
+			data.Add("topics[]", topic)
+		}
+	}
+
+	if len(remote.DefaultBranch) > 0 && remote.DefaultBranch != project.DefaultBranch {
+		data.Add("default_branch", remote.DefaultBranch)
+	}
+
+	return data
+}
+
+// sameTopics compares two lists of topics regardless of their order and case.
+func sameTopics(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	normalize := func(topics []string) []string {
+		result := make([]string, len(topics))
+		for i, topic := range topics {
+			result[i] = strings.ToLower(topic)
+		}
+		sort.Strings(result)
+		return result
+	}
+
+	a, b = normalize(a), normalize(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// syncMetadata updates the description, topics and default branch of the project when they changed in the source.
+// The default branch must already be in GitLab, so it runs after the git data is synced.
+func syncMetadata(ctx context.Context, prj *Project, log *slog.Logger) error {
+	changes := metadataChanges(prj.SourceRepository, prj.DestinationRepository)
+	if len(changes) == 0 {
+		return nil
+	}
+
+	log.Info("Updating project metadata", "fields", sortedKeys(changes))
+	if err := prj.Destination.UpdateProject(ctx, *prj.DestinationRepository.ID, changes); err != nil {
+		return fmt.Errorf("updating project metadata: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"main/src/sources"
+	"main/src/utils"
+	"net/url"
+	"testing"
+)
+
+func TestProjectDescription(t *testing.T) {
+	tests := []struct {
+		desc   string
+		remote sources.SourceRepository
+		want   *string
+	}{
+		{"unknown", sources.SourceRepository{}, nil},
+		{"empty", sources.SourceRepository{Description: utils.Pointer("")}, utils.Pointer("")},
+		{"description", sources.SourceRepository{Description: utils.Pointer("A project")}, utils.Pointer("A project")},
+		{"homepage", sources.SourceRepository{Homepage: utils.Pointer("https://example.com")}, utils.Pointer("Homepage: https://example.com")},
+		{"both", sources.SourceRepository{Description: utils.Pointer("A project"), Homepage: utils.Pointer("https://example.com")}, utils.Pointer("A project\n\nHomepage: https://example.com")},
+		{"empty homepage", sources.SourceRepository{Description: utils.Pointer("A project"), Homepage: utils.Pointer("")}, utils.Pointer("A project")},
+	}
+
+	for _, tt := range tests {
+		got := projectDescription(tt.remote)
+		if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+			t.Errorf("%s: projectDescription = %v, want %v", tt.desc, got, tt.want)
+		}
+	}
+}
+
+func TestMetadataChanges(t *testing.T) {
+	project := &ProjectGitLab{
+		Description:   "A project",
+		Topics:        []string{"go", "backup"},
+		DefaultBranch: "main",
+	}
+
+	tests := []struct {
+		desc   string
+		remote sources.SourceRepository
+		want   url.Values
+	}{
+		{"unknown metadata", sources.SourceRepository{}, url.Values{}},
+		{"unchanged", sources.SourceRepository{
+			Description:   utils.Pointer("A project"),
+			Topics:        []string{"Backup", "go"},
+			DefaultBranch: "main",
+		}, url.Values{}},
+		{"description", sources.SourceRepository{Description: utils.Pointer("Another project")}, url.Values{
+			"description": {"Another project"},
+		}},
+		{"cleared description", sources.SourceRepository{Description: utils.Pointer("")}, url.Values{
+			"description": {""},
+		}},
+		{"topics", sources.SourceRepository{Topics: []string{"go", "gitlab"}}, url.Values{
+			"topics[]": {"go", "gitlab"},
+		}},
+		{"cleared topics", sources.SourceRepository{Topics: []string{}}, url.Values{
+			"topics": {""},
+		}},
+		{"default branch", sources.SourceRepository{DefaultBranch: "master"}, url.Values{
+			"default_branch": {"master"},
+		}},
+	}
+
+	for _, tt := range tests {
+		got := metadataChanges(tt.remote, project)
+		if got.Encode() != tt.want.Encode() {
+			t.Errorf("%s: changes = %s, want %s", tt.desc, got.Encode(), tt.want.Encode())
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package metrics
+
+var (
+	Repositories = NewCounterVec("gitbackup_repositories_total",
+		"Repositories evaluated, by outcome (synced, failed, skipped or deleted).",
+		"source", "group", "status")
+
+	LastSuccess = NewGaugeVec("gitbackup_repository_last_success_timestamp_seconds",
+		"Unix timestamp of the last successful sync of a repository.",
+		"source", "group", "repository")
+
+	DownloadedBytes = NewCounterVec("gitbackup_asset_downloaded_bytes_total",
+		"Bytes of release assets downloaded from the sources.")
+
+	UploadedBytes = NewCounterVec("gitbackup_asset_uploaded_bytes_total",
+		"Bytes of release assets uploaded to Dufs.")
+
+	GitLabRequests = NewCounterVec("gitbackup_gitlab_requests_total",
+		"Requests made to the GitLab API, by method and status code.",
+		"method", "code")
+
+	GitLabRequestDuration = NewHistogramVec("gitbackup_gitlab_request_duration_seconds",
+		"Latency of the requests made to the GitLab API.",
+		[]float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+		"method", "code")
+
+	GitHubRateLimitRemaining = NewGaugeVec("gitbackup_github_rate_limit_remaining",
+		"Requests left in the current GitHub API rate limit window.")
+
+	GitHubRateLimitReset = NewGaugeVec("gitbackup_github_rate_limit_reset_timestamp_seconds",
+		"Unix timestamp when the GitHub API rate limit window resets.")
+)
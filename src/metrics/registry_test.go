@@ -0,0 +1,127 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCounterVec(t *testing.T) {
+	counter := NewCounterVec("test_counter_total", "A counter.", "source", "status")
+	counter.Inc("github", "synced")
+	counter.Add(2, "github", "synced")
+	counter.Inc("github", "failed")
+	counter.Inc("huggingface", `quote" backslash\ newline
+tab	`)
+
+	var b strings.Builder
+	counter.write(&b)
+
+	want := `# HELP test_counter_total A counter.
+# TYPE test_counter_total counter
+test_counter_total{source="github",status="failed"} 1
+test_counter_total{source="github",status="synced"} 3
+test_counter_total{source="huggingface",status="quote\" backslash\\ newline\ntab"} 1
+`
+	if b.String() != want {
+		t.Errorf("counter exposition:\n%s\nwant:\n%s", b.String(), want)
+	}
+}
+
+func TestCounterVecWithoutLabels(t *testing.T) {
+	counter := NewCounterVec("test_unlabeled_total", "A counter without labels.")
+
+	var b strings.Builder
+	counter.write(&b)
+
+	// Reported as 0 before the first increment
+	want := `# HELP test_unlabeled_total A counter without labels.
+# TYPE test_unlabeled_total counter
+test_unlabeled_total 0
+`
+	if b.String() != want {
+		t.Errorf("counter exposition:\n%s\nwant:\n%s", b.String(), want)
+	}
+}
+
+func TestGaugeVec(t *testing.T) {
+	gauge := NewGaugeVec("test_gauge", "A gauge.", "repository")
+	gauge.Set(1705312800, "project")
+	gauge.Set(0.5, "other")
+	gauge.Set(2, "other")
+
+	var b strings.Builder
+	gauge.write(&b)
+
+	want := `# HELP test_gauge A gauge.
+# TYPE test_gauge gauge
+test_gauge{repository="other"} 2
+test_gauge{repository="project"} 1.7053128e+09
+`
+	if b.String() != want {
+		t.Errorf("gauge exposition:\n%s\nwant:\n%s", b.String(), want)
+	}
+}
+
+func TestHistogramVec(t *testing.T) {
+	histogram := NewHistogramVec("test_duration_seconds", "A histogram.", []float64{0.1, 1, 10}, "method")
+	histogram.Observe(0.05, "GET")
+	histogram.Observe(0.5, "GET")
+	histogram.Observe(20, "GET")
+	histogram.Observe(1, "POST")
+
+	var b strings.Builder
+	histogram.write(&b)
+
+	want := `# HELP test_duration_seconds A histogram.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{method="GET",le="0.1"} 1
+test_duration_seconds_bucket{method="GET",le="1"} 2
+test_duration_seconds_bucket{method="GET",le="10"} 2
+test_duration_seconds_bucket{method="GET",le="+Inf"} 3
+test_duration_seconds_sum{method="GET"} 20.55
+test_duration_seconds_count{method="GET"} 3
+test_duration_seconds_bucket{method="POST",le="0.1"} 0
+test_duration_seconds_bucket{method="POST",le="1"} 1
+test_duration_seconds_bucket{method="POST",le="10"} 1
+test_duration_seconds_bucket{method="POST",le="+Inf"} 1
+test_duration_seconds_sum{method="POST"} 1
+test_duration_seconds_count{method="POST"} 1
+`
+	if b.String() != want {
+		t.Errorf("histogram exposition:\n%s\nwant:\n%s", b.String(), want)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	NewGaugeVec("test_handler_gauge", "A registered gauge.").Set(42)
+
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if contentType := rec.Header().Get("Content-Type"); contentType != "text/plain; version=0.0.4; charset=utf-8" {
+		t.Errorf("Content-Type = %q", contentType)
+	}
+
+	body := rec.Body.String()
+	for _, line := range []string{
+		"# TYPE gitbackup_repositories_total counter",
+		"# TYPE gitbackup_gitlab_request_duration_seconds histogram",
+		"test_handler_gauge 42",
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("exposition is missing %q", line)
+		}
+	}
+}
+
+func TestPanicOnLabelCount(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("a wrong number of label values did not panic")
+		}
+	}()
+
+	NewCounterVec("test_panic_total", "A counter.", "source").Inc("github", "extra")
+}
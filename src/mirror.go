@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"main/src/utils"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	// MirrorPush clones the source repository and pushes it to GitLab
+	MirrorPush = "push"
+
+	// MirrorGitLabPull lets GitLab pull the source repository itself
+	MirrorGitLabPull = "gitlab_pull"
+)
+
+type pullMirrorStatus struct {
+	UpdateStatus string     `json:"update_status"`
+	LastError    *string    `json:"last_error"`
+	LastUpdateAt *time.Time `json:"last_update_at"`
+}
+
+// EnablePullMirror configures the project to pull mirror its source repository.
+func (g *Project) EnablePullMirror(ctx context.Context) error {
+	data := url.Values{}
+	data.Add("import_url", g.SourceRepository.URL)
+	data.Add("mirror", "true")
+
+	urlPath := fmt.Sprintf("/api/v4/projects/%d", *g.DestinationRepository.ID)
+	body, err := g.Destination.Request(ctx, http.MethodPut, urlPath, []byte(data.Encode()))
+	if err != nil {
+		return err
+	}
+
+	if body.Status != http.StatusOK {
+		return fmt.Errorf("enabling pull mirror: status %d: %s", body.Status, body.Body)
+	}
+
+	g.DestinationRepository.Mirror = true
+	return nil
+}
+
+// TriggerPullMirror asks GitLab to update the pull mirror now instead of on its own schedule.
+func (g *Project) TriggerPullMirror(ctx context.Context) error {
+	urlPath := fmt.Sprintf("/api/v4/projects/%d/mirror/pull", *g.DestinationRepository.ID)
+	body, err := g.Destination.Request(ctx, http.MethodPost, urlPath, nil)
+	if err != nil {
+		return err
+	}
+
+	if body.Status >= 300 {
+		return fmt.Errorf("triggering pull mirror: status %d: %s", body.Status, body.Body)
+	}
+
+	return nil
+}
+
+// PullMirrorStatus retrieves the status of the last update of the pull mirror.
+func (g *Project) PullMirrorStatus(ctx context.Context) (*pullMirrorStatus, error) {
+	urlPath := fmt.Sprintf("/api/v4/projects/%d/mirror/pull", *g.DestinationRepository.ID)
+	body, err := g.Destination.Request(ctx, http.MethodGet, urlPath, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if body.Status != http.StatusOK {
+		return nil, fmt.Errorf("retrieving pull mirror status: status %d", body.Status)
+	}
+
+	var status pullMirrorStatus
+	if err := json.Unmarshal(body.Body, &status); err != nil {
+		return nil, fmt.Errorf("parsing pull mirror status: %w", err)
+	}
+
+	return &status, nil
+}
+
+// LockUntilPullMirror polls the pull mirror until an update newer than the one of previous, the
+// last_update_at read before the trigger, finishes, fails or exceeds the import timeout.
+func (g *Project) LockUntilPullMirror(ctx context.Context, previous *time.Time) error {
+	timeout := g.Destination.ImportTimeout
+	deadline := time.Now().Add(timeout)
+
+	for {
+		status, err := g.PullMirrorStatus(ctx)
+		if err != nil {
+			return err
+		}
+
+		// A finished or failed status from the update before the trigger is not the one waited for.
+		// Only GitLab timestamps are compared, as the local clock may be skewed.
+		current := status.LastUpdateAt != nil && (previous == nil || !status.LastUpdateAt.Equal(*previous))
+
+		switch {
+		case current && status.UpdateStatus == "finished":
+			return nil
+		case current && status.UpdateStatus == "failed":
+			mirrorErr := &ImportError{Status: "failed"}
+			if status.LastError != nil {
+				mirrorErr.Message = *status.LastError
+			}
+			return mirrorErr
+		default:
+			g.Log.Debug("Waiting for pull mirror", "update_status", status.UpdateStatus)
+		}
+
+		if time.Now().After(deadline) {
+			return &ImportError{Status: "timed out", Message: fmt.Sprintf("pull mirror still %s after %s", status.UpdateStatus, timeout)}
+		}
+
+		if err := utils.Sleep(ctx, g.Destination.PollInterval); err != nil {
+			return err
+		}
+	}
+}
+
+// pullMirror makes GitLab update the project from its source, enabling the pull mirror first when needed.
+func pullMirror(ctx context.Context, prj *Project, log *slog.Logger) error {
+	if !prj.DestinationRepository.Mirror {
+		log.Info("Enabling GitLab pull mirror")
+		if err := prj.EnablePullMirror(ctx); err != nil {
+			return err
+		}
+	}
+
+	previous, err := prj.PullMirrorStatus(ctx)
+	if err != nil {
+		return err
+	}
+
+	log.Info("Triggering GitLab pull mirror")
+	if err := prj.TriggerPullMirror(ctx); err != nil {
+		return err
+	}
+
+	log.Info("Waiting for pull mirror to finish")
+	return prj.LockUntilPullMirror(ctx, previous.LastUpdateAt)
+}
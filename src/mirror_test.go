@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLockUntilPullMirror(t *testing.T) {
+	previous := time.Date(2026, 10, 19, 3, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		desc      string
+		previous  *time.Time
+		responses []string
+		want      *ImportError
+	}{
+		{"first update", nil, []string{
+			`{"update_status": "none", "last_update_at": null}`,
+			`{"update_status": "finished", "last_update_at": "2026-10-19T03:00:00Z"}`,
+		}, nil},
+		{"update after a previous one", &previous, []string{
+			`{"update_status": "finished", "last_update_at": "2026-10-19T03:00:00Z"}`,
+			`{"update_status": "started", "last_update_at": "2026-10-19T03:00:00Z"}`,
+			`{"update_status": "finished", "last_update_at": "2026-10-19T04:00:00Z"}`,
+		}, nil},
+		{"failed update", &previous, []string{
+			`{"update_status": "failed", "last_update_at": "2026-10-19T04:00:00Z", "last_error": "unreachable"}`,
+		}, &ImportError{Status: "failed", Message: "unreachable"}},
+		{"previous failure", &previous, []string{
+			`{"update_status": "failed", "last_update_at": "2026-10-19T03:00:00Z", "last_error": "unreachable"}`,
+		}, &ImportError{Status: "timed out"}},
+		{"last_update_at never changes", &previous, []string{
+			`{"update_status": "finished", "last_update_at": "2026-10-19T03:00:00Z"}`,
+		}, &ImportError{Status: "timed out"}},
+	}
+
+	for _, tt := range tests {
+		prj := testProject(t, tt.responses...)
+		prj.Destination.ImportTimeout = 20 * time.Millisecond
+
+		err := prj.LockUntilPullMirror(context.Background(), tt.previous)
+		if tt.want == nil {
+			if err != nil {
+				t.Errorf("%s: unexpected error %v", tt.desc, err)
+			}
+			continue
+		}
+
+		var mirrorErr *ImportError
+		if !errors.As(err, &mirrorErr) {
+			t.Errorf("%s: error = %v, want an import error", tt.desc, err)
+			continue
+		}
+		if mirrorErr.Status != tt.want.Status || (len(tt.want.Message) > 0 && mirrorErr.Message != tt.want.Message) {
+			t.Errorf("%s: error = %v, want %v", tt.desc, mirrorErr, tt.want)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"main/src/sources"
+	"regexp"
+	"strings"
+)
+
+// reservedNameSuffix is appended to the names GitLab reserves for its own routes.
+const reservedNameSuffix = "-repo"
+
+// invalidNameChars matches the characters GitLab does not accept in project names.
+var invalidNameChars = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+// sanitizeName turns a name into a valid GitLab project name, replacing the invalid characters by '-'
+// and a leading '.' by '_'.
+func sanitizeName(name string) string {
+	name = invalidNameChars.ReplaceAllString(name, "-")
+
+	for changed := true; changed; {
+		trimmed := strings.Trim(name, "-")
+
+		switch {
+		case strings.HasPrefix(trimmed, "."):
+			// GitLab names start with a letter, a digit or '_'
+			trimmed = "_" + strings.TrimLeft(trimmed, ".")
+		case strings.HasSuffix(trimmed, "."):
+			trimmed = strings.TrimSuffix(trimmed, ".")
+		case strings.HasSuffix(trimmed, ".git"):
+			trimmed = strings.TrimSuffix(trimmed, ".git") + "-git"
+		case strings.HasSuffix(trimmed, ".atom"):
+			trimmed = strings.TrimSuffix(trimmed, ".atom") + "-atom"
+		}
+
+		changed = trimmed != name
+		name = trimmed
+	}
+
+	if len(name) == 0 {
+		return "repo"
+	}
+
+	return name
+}
+
+// projectName returns the GitLab name of a source repository: its gitlab_name or the name_template
+// of the group, made into a valid GitLab name that is not reserved.
+func projectName(gitlab *GitLab, groupCfg ConfigGroup, remote sources.SourceRepository) string {
+	name := strings.NewReplacer(
+		"{name}", remote.Name,
+		"{username}", groupCfg.Username,
+		"{source}", groupCfg.Source,
+		"{type}", remote.Type,
+	).Replace(*groupCfg.NameTemplate)
+
+	if repo := groupCfg.GetConfig(remote.Name); repo != nil && repo.GitLabName != nil {
+		name = *repo.GitLabName
+	}
+
+	name = sanitizeName(name)
+	if gitlab.IsReservedName(name) {
+		name += reservedNameSuffix
+	}
+
+	return name
+}
+
+// projectNames maps the clone urls of the source repositories to their GitLab names. When names collide,
+// a repository whose project already has the name keeps it, or else the first repository in the listing
+// that keeps its source name. The others get a hash of their clone url appended, so each repository keeps
+// its own project. projects are the existing projects of the group.
+func projectNames(gitlab *GitLab, groupCfg ConfigGroup, remotes []sources.SourceRepository, projects []groupProject) map[string]string {
+	names := make(map[string]string, len(remotes))
+	byName := make(map[string][]sources.SourceRepository)
+
+	for _, remote := range remotes {
+		name := projectName(gitlab, groupCfg, remote)
+		names[remote.URL] = name
+
+		// GitLab names are case insensitive
+		key := strings.ToLower(name)
+		byName[key] = append(byName[key], remote)
+	}
+
+	owners := make(map[string]string, len(projects))
+	for _, project := range projects {
+		if len(project.OriginalURL) > 0 {
+			owners[normalizeURL(project.OriginalURL)] = strings.ToLower(project.Name)
+		}
+	}
+
+	for key, colliding := range byName {
+		if len(colliding) < 2 {
+			continue
+		}
+
+		kept := make(map[string]bool)
+		for _, remote := range colliding {
+			if owners[normalizeURL(remote.URL)] == key {
+				kept[remote.URL] = true
+			}
+		}
+
+		if len(kept) == 0 {
+			for _, remote := range colliding {
+				if names[remote.URL] == remote.Name {
+					kept[remote.URL] = true
+					break
+				}
+			}
+		}
+
+		for _, remote := range colliding {
+			if kept[remote.URL] {
+				continue
+			}
+
+			sum := sha256.Sum256([]byte(remote.URL))
+			names[remote.URL] += "-" + hex.EncodeToString(sum[:4])
+		}
+	}
+
+	return names
+}
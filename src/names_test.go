@@ -0,0 +1,119 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"main/src/sources"
+	"main/src/utils"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSanitizeName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"project", "project"},
+		{"my project", "my-project"},
+		{"a/b\\c", "a-b-c"},
+		{"--project--", "project"},
+		{"project.", "project"},
+		{"project...", "project"},
+		{"project.git", "project-git"},
+		{"project.atom", "project-atom"},
+		{"project.git.", "project-git"},
+		{".github", "_github"},
+		{"..dotfiles", "_dotfiles"},
+		{"-.config", "_config"},
+		{"---", "repo"},
+		{"", "repo"},
+		{"日本語", "repo"},
+	}
+
+	gitlab := &GitLab{}
+	for _, tt := range tests {
+		got := sanitizeName(tt.name)
+		if got != tt.want {
+			t.Errorf("sanitizeName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+		if !gitlab.IsValidName(got) {
+			t.Errorf("sanitizeName(%q) = %q, which is not a valid GitLab name", tt.name, got)
+		}
+	}
+}
+
+func TestIsReservedName(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"badges", true},
+		{"Badges", true},
+		{"TREE", true},
+		{"badges-repo", false},
+		{"project", false},
+	}
+
+	gitlab := &GitLab{}
+	for _, tt := range tests {
+		if got := gitlab.IsReservedName(tt.name); got != tt.want {
+			t.Errorf("IsReservedName(%q) = %t, want %t", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestProjectNames(t *testing.T) {
+	model := sources.SourceRepository{Name: "bert", URL: "https://huggingface.co/user/bert.git", Type: sources.TypeModel}
+	dataset := sources.SourceRepository{Name: "bert", URL: "https://huggingface.co/datasets/user/bert.git", Type: sources.TypeDataset}
+	upper := sources.SourceRepository{Name: "Bert", URL: "https://github.com/user/Bert.git"}
+	renamed := sources.SourceRepository{Name: "bert!", URL: "https://github.com/user/bert!.git"}
+	question := sources.SourceRepository{Name: "bert?", URL: "https://github.com/user/bert%3F.git"}
+	other := sources.SourceRepository{Name: "gpt", URL: "https://huggingface.co/user/gpt.git"}
+
+	// The project of bert! was created before bert existed upstream
+	renamedProject := groupProject{ProjectGitLab: ProjectGitLab{Name: "bert"}, OriginalURL: renamed.URL}
+	otherProject := groupProject{ProjectGitLab: ProjectGitLab{Name: "bert"}, OriginalURL: "https://github.com/someone/bert.git"}
+
+	tests := []struct {
+		desc     string
+		remotes  []sources.SourceRepository
+		projects []groupProject
+		hashed   []string
+	}{
+		{"no collision", []sources.SourceRepository{model, other}, nil, nil},
+		{"model and dataset", []sources.SourceRepository{model, dataset}, nil, []string{dataset.URL}},
+		{"case only", []sources.SourceRepository{model, upper}, nil, []string{upper.URL}},
+		{"changed by the mapping", []sources.SourceRepository{renamed, model}, nil, []string{renamed.URL}},
+		{"all changed", []sources.SourceRepository{renamed, question}, nil, []string{renamed.URL, question.URL}},
+		{"existing project keeps its name", []sources.SourceRepository{model, renamed}, []groupProject{renamedProject}, []string{model.URL}},
+		{"project of another repository", []sources.SourceRepository{renamed, model}, []groupProject{otherProject}, []string{renamed.URL}},
+	}
+
+	groupCfg := ConfigGroup{NameTemplate: utils.Pointer("{name}")}
+	for _, tt := range tests {
+		names := projectNames(&GitLab{}, groupCfg, tt.remotes, tt.projects)
+		if len(names) != len(tt.remotes) {
+			t.Errorf("%s: got %d names, want %d", tt.desc, len(names), len(tt.remotes))
+		}
+
+		seen := make(map[string]bool)
+		for _, remote := range tt.remotes {
+			name := names[remote.URL]
+			if seen[strings.ToLower(name)] {
+				t.Errorf("%s: %s is mapped to the duplicate name %q", tt.desc, remote.URL, name)
+			}
+			seen[strings.ToLower(name)] = true
+
+			want := sanitizeName(remote.Name)
+			if slices.Contains(tt.hashed, remote.URL) {
+				sum := sha256.Sum256([]byte(remote.URL))
+				want += "-" + hex.EncodeToString(sum[:4])
+			}
+			if name != want {
+				t.Errorf("%s: %s is mapped to %q, want %q", tt.desc, remote.URL, name, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"main/src/utils"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strconv"
+	"strings"
+)
+
+const (
+	NotificationSMTP    = "smtp"
+	NotificationWebhook = "webhook"
+	NotificationSlack   = "slack"
+
+	NotifyAlways  = "always"
+	NotifyChanges = "changes"
+	NotifyFailure = "failure"
+)
+
+// RunSummary is the part of a run report sent in notifications.
+type RunSummary struct {
+	Run      int      `json:"run"`
+	Trigger  string   `json:"trigger"`
+	Total    int      `json:"total"`
+	Created  []string `json:"created"`
+	Updated  int      `json:"updated"`
+	Skipped  int      `json:"skipped"`
+	Failures []string `json:"failures"`
+	Deleted  []string `json:"deleted"`
+}
+
+func NewRunSummary(report *RunReport) RunSummary {
+	summary := RunSummary{
+		Run:      report.Run.ID,
+		Trigger:  report.Run.Trigger,
+		Total:    len(report.Repositories),
+		Created:  make([]string, 0),
+		Failures: make([]string, 0),
+		Deleted:  make([]string, 0),
+	}
+
+	for _, failure := range report.GroupFailures {
+		summary.Failures = append(summary.Failures, fmt.Sprintf("%s: %s", failure.Name(), failure.Error))
+	}
+
+	for _, repo := range report.Repositories {
+		name := fmt.Sprintf("%s/%s/%s", repo.Source, repo.Username, repo.Name)
+
+		switch repo.Outcome {
+		case OutcomeCreated:
+			summary.Created = append(summary.Created, name)
+		case OutcomeUpdated:
+			summary.Updated++
+		case OutcomeSkipped:
+			summary.Skipped++
+		case OutcomeFailed:
+			summary.Failures = append(summary.Failures, fmt.Sprintf("%s: %s", name, repo.Error))
+		case OutcomeDeleted:
+			summary.Deleted = append(summary.Deleted, fmt.Sprintf("%s (%s)", name, repo.Reason))
+		}
+	}
+
+	return summary
+}
+
+func (s RunSummary) Subject() string {
+	if len(s.Failures) > 0 {
+		return fmt.Sprintf("git-backup run %d: %d failures", s.Run, len(s.Failures))
+	}
+
+	return fmt.Sprintf("git-backup run %d: %d repositories synced", s.Run, s.Total-s.Skipped)
+}
+
+// Text renders the summary as plain text.
+func (s RunSummary) Text() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "%s\n\n", s.Subject())
+	fmt.Fprintf(&b, "Trigger: %s\n", s.Trigger)
+	fmt.Fprintf(&b, "Repositories: %d created, %d updated, %d skipped, %d failed\n",
+		len(s.Created), s.Updated, s.Skipped, len(s.Failures))
+
+	sections := []struct {
+		title string
+		lines []string
+	}{
+		{"Failures", s.Failures},
+		{"New projects", s.Created},
+		{"Deleted upstream", s.Deleted},
+	}
+	for _, section := range sections {
+		if len(section.lines) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(&b, "\n%s:\n", section.title)
+		for _, line := range section.lines {
+			fmt.Fprintf(&b, "- %s\n", line)
+		}
+	}
+
+	return b.String()
+}
+
+// ShouldNotify reports whether the summary passes the threshold of a notification.
+func (s RunSummary) ShouldNotify(cfg ConfigNotification) bool {
+	switch *cfg.On {
+	case NotifyAlways:
+		return true
+	case NotifyChanges:
+		return len(s.Failures) >= *cfg.MinFailures || len(s.Created) > 0 || len(s.Deleted) > 0
+	default:
+		return len(s.Failures) >= *cfg.MinFailures
+	}
+}
+
+// Notify sends the summary of the run to every configured channel whose threshold it passes.
+func Notify(config *Configuration, report *RunReport) {
+	if report == nil {
+		return
+	}
+
+	summary := NewRunSummary(report)
+	for _, cfg := range config.Notifications {
+		if !summary.ShouldNotify(cfg) {
+			continue
+		}
+
+		var err error
+		switch cfg.Type {
+		case NotificationSMTP:
+			err = sendMail(*cfg.SMTP, summary)
+		case NotificationWebhook:
+			err = postJSON(*cfg.URL, summary)
+		case NotificationSlack:
+			err = postJSON(*cfg.URL, map[string]string{"text": summary.Text()})
+		}
+
+		if err != nil {
+			slog.Error("Sending notification", "type", cfg.Type, "error", err)
+		} else {
+			slog.Info("Sent notification", "type", cfg.Type)
+		}
+	}
+}
+
+func postJSON(url string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := utils.DoRequest(req)
+	if err != nil {
+		return fmt.Errorf("error performing request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("received non-success status code: %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+func sendMail(cfg ConfigSMTP, summary RunSummary) error {
+	addr := net.JoinHostPort(cfg.Host, strconv.Itoa(*cfg.Port))
+
+	var auth smtp.Auth
+	if len(cfg.Username) > 0 {
+		auth = smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)
+	}
+
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", cfg.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(cfg.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", summary.Subject())
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(summary.Text(), "\n", "\r\n"))
+
+	return smtp.SendMail(addr, auth, cfg.From, cfg.To, []byte(msg.String()))
+}
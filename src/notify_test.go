@@ -0,0 +1,42 @@
+package main
+
+import (
+	"main/src/utils"
+	"testing"
+)
+
+func TestShouldNotify(t *testing.T) {
+	quiet := RunSummary{Total: 3, Updated: 3}
+	created := RunSummary{Total: 3, Updated: 2, Created: []string{"github/alice/new"}}
+	deleted := RunSummary{Total: 3, Updated: 3, Deleted: []string{"github/alice/old (deleted upstream)"}}
+	failed := RunSummary{Total: 3, Updated: 2, Failures: []string{"github/alice/broken: timeout"}}
+	failedTwice := RunSummary{Total: 3, Updated: 1, Failures: []string{"github/alice/broken: timeout", "run: locked"}}
+
+	tests := []struct {
+		desc        string
+		on          string
+		minFailures int
+		summary     RunSummary
+		want        bool
+	}{
+		{"always without changes", NotifyAlways, 1, quiet, true},
+		{"always under the threshold", NotifyAlways, 5, failed, true},
+		{"changes without changes", NotifyChanges, 1, quiet, false},
+		{"changes with a new project", NotifyChanges, 1, created, true},
+		{"changes with a deletion", NotifyChanges, 1, deleted, true},
+		{"changes with a failure", NotifyChanges, 1, failed, true},
+		{"changes under the threshold", NotifyChanges, 2, failed, false},
+		{"failure without failures", NotifyFailure, 1, quiet, false},
+		{"failure with changes", NotifyFailure, 1, created, false},
+		{"failure with a failure", NotifyFailure, 1, failed, true},
+		{"failure under the threshold", NotifyFailure, 2, failed, false},
+		{"failure at the threshold", NotifyFailure, 2, failedTwice, true},
+	}
+
+	for _, tt := range tests {
+		cfg := ConfigNotification{On: utils.Pointer(tt.on), MinFailures: utils.Pointer(tt.minFailures)}
+		if got := tt.summary.ShouldNotify(cfg); got != tt.want {
+			t.Errorf("%s: ShouldNotify = %t, want %t", tt.desc, got, tt.want)
+		}
+	}
+}
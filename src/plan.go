@@ -0,0 +1,294 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"main/src/sources"
+	"main/src/utils"
+	"os"
+)
+
+const (
+	PlanActionCreate = "create"
+	PlanActionUpdate = "update"
+	PlanActionSkip   = "skip"
+
+	PlanAssetUpload = "upload"
+	PlanAssetLink   = "link"
+)
+
+// Plan describes what a sync would do, without changing anything in GitLab or Dufs.
+type Plan struct {
+	Groups []PlanGroup `json:"groups"`
+
+	ProjectsToCreate int   `json:"projects_to_create"`
+	ProjectsToUpdate int   `json:"projects_to_update"`
+	Skipped          int   `json:"skipped"`
+	MissingReleases  int   `json:"missing_releases"`
+	AssetBytes       int64 `json:"asset_bytes"`
+}
+
+type PlanGroup struct {
+	Source        string           `json:"source"`
+	Username      string           `json:"username"`
+	GitLabGroupID int              `json:"gitlab_group_id"`
+	GitLabPath    string           `json:"gitlab_path,omitempty"`
+	Repositories  []PlanRepository `json:"repositories"`
+	Error         string           `json:"error,omitempty"`
+}
+
+type PlanRepository struct {
+	Name      string `json:"name"`
+	Action    string `json:"action"`
+	Reason    string `json:"reason,omitempty"`
+	ProjectID *int   `json:"project_id,omitempty"`
+	Wiki      bool   `json:"wiki"`
+
+	MissingReleases []PlanRelease `json:"missing_releases"`
+	AssetBytes      int64         `json:"asset_bytes"`
+
+	Error string `json:"error,omitempty"`
+}
+
+type PlanRelease struct {
+	TagName string      `json:"tag_name"`
+	Assets  []PlanAsset `json:"assets"`
+}
+
+type PlanAsset struct {
+	Name   string `json:"name"`
+	URL    string `json:"url"`
+	Size   int64  `json:"size"`
+	Action string `json:"action"`
+}
+
+func PlanUser(ctx context.Context, gitlab *GitLab, dufs *Dufs, sourceCfg ConfigRepo, groupCfg ConfigGroup, source sources.Source, opts *Options) PlanGroup {
+	group := PlanGroup{
+		Source:       groupCfg.Source,
+		Username:     groupCfg.Username,
+		Repositories: make([]PlanRepository, 0),
+	}
+
+	// Missing groups are not created, their ID is -1
+	groupCfg, err := resolveGroup(ctx, gitlab, groupCfg, false)
+	if err != nil {
+		group.Error = err.Error()
+		return group
+	}
+
+	group.GitLabGroupID = *groupCfg.GitLabGroupID
+	if groupCfg.GitLabPath != nil {
+		group.GitLabPath = *groupCfg.GitLabPath
+	}
+
+	remotes, listErr := listRepositories(ctx, source, groupCfg.Username)
+
+	// Collisions are detected over the complete listing, as in a sync
+	projects, projectsErr := listGroupProjects(ctx, gitlab, groupCfg)
+	if err := errors.Join(listErr, projectsErr); err != nil {
+		group.Error = err.Error()
+	}
+	names := projectNames(gitlab, groupCfg, remotes, projects)
+
+	count := 1
+	for _, remote := range remotes {
+		// Filtered out repositories still take their position, so skip applies as in a full run
+		position := count
+		cfg, reason, next := resolveRemote(sourceCfg, groupCfg, remote, count)
+		count = next
+		if !opts.MatchRepository(remote.Name) {
+			continue
+		}
+
+		if len(reason) > 0 {
+			group.Repositories = append(group.Repositories, PlanRepository{
+				Name:   remote.Name,
+				Action: PlanActionSkip,
+				Reason: reason,
+			})
+			continue
+		}
+
+		slog.Info("Planning repository", "source", groupCfg.Source, "group", groupCfg.Username, "repo", remote.Name, "count", position)
+		repoGroup, err := repositoryGroup(ctx, gitlab, groupCfg, remote, false)
+		if err != nil {
+			group.Repositories = append(group.Repositories, PlanRepository{Name: remote.Name, Error: err.Error()})
+			continue
+		}
+
+		prj := NewProject(gitlab, dufs, *repoGroup.GitLabGroupID, names[remote.URL], source, groupCfg.Username, remote, cfg)
+		prj.Only = opts.Only
+		group.Repositories = append(group.Repositories, PlanRepo(ctx, prj))
+	}
+
+	return group
+}
+
+func PlanRepo(ctx context.Context, prj *Project) PlanRepository {
+	repo := PlanRepository{
+		Name:            prj.SourceRepository.Name,
+		Action:          PlanActionUpdate,
+		Wiki:            !*prj.Config.Wiki.Exclude && prj.ShouldSync(OnlyWiki) && len(prj.Source.GetWikiURL(prj.SourceUsername, prj.SourceRepository.Name)) > 0,
+		MissingReleases: make([]PlanRelease, 0),
+	}
+
+	repoID, err := prj.RetrieveExistingRepo(ctx)
+	if err != nil {
+		repo.Error = err.Error()
+		return repo
+	}
+
+	if repoID == -1 {
+		repo.Action = PlanActionCreate
+	} else {
+		repo.ProjectID = &repoID
+	}
+
+	if *prj.Config.Releases.Exclude || !prj.ShouldSync(OnlyReleases) {
+		return repo
+	}
+
+	releases, err := prj.Source.FetchReleases(ctx, prj.SourceUsername, prj.SourceRepository.Name)
+	if err != nil {
+		repo.Error = err.Error()
+		return repo
+	}
+
+	for _, release := range releases {
+		// A project that does not exist yet has no releases
+		if repoID != -1 {
+			exists, err := prj.ReleaseExists(ctx, release.TagName)
+			if err != nil {
+				repo.Error = err.Error()
+				return repo
+			}
+
+			if exists {
+				continue
+			}
+		}
+
+		planRelease := PlanRelease{
+			TagName: release.TagName,
+			Assets:  make([]PlanAsset, 0, len(release.Assets)),
+		}
+
+		for _, asset := range release.Assets {
+			planAsset := PlanAsset{
+				Name:   asset.Name,
+				URL:    asset.BrowserDownloadUrl,
+				Size:   -1,
+				Action: PlanAssetLink,
+			}
+
+			if !*prj.Config.Releases.Assets.Exclude {
+				size, err := utils.GetRemoteFileSize(ctx, asset.BrowserDownloadUrl)
+				if err != nil {
+					prj.Log.Warn("Retrieving asset size", "release", release.TagName, "asset", asset.Name, "error", err)
+				}
+				planAsset.Size = size
+
+				maxSize := *prj.Config.Releases.Assets.MaxSize
+				if maxSize == "none" || size < utils.ConvertToBytes(maxSize) {
+					planAsset.Action = PlanAssetUpload
+					if size > 0 {
+						repo.AssetBytes += size
+					}
+				}
+			}
+
+			planRelease.Assets = append(planRelease.Assets, planAsset)
+		}
+
+		repo.MissingReleases = append(repo.MissingReleases, planRelease)
+	}
+
+	return repo
+}
+
+// Add appends a group to the plan and updates the totals.
+func (p *Plan) Add(group PlanGroup) {
+	for _, repo := range group.Repositories {
+		switch repo.Action {
+		case PlanActionCreate:
+			p.ProjectsToCreate++
+		case PlanActionUpdate:
+			p.ProjectsToUpdate++
+		case PlanActionSkip:
+			p.Skipped++
+		}
+
+		p.MissingReleases += len(repo.MissingReleases)
+		p.AssetBytes += repo.AssetBytes
+	}
+
+	p.Groups = append(p.Groups, group)
+}
+
+func (p *Plan) Print() {
+	for _, group := range p.Groups {
+		fmt.Println("\n================================================")
+		switch {
+		case group.GitLabGroupID == -1:
+			fmt.Printf("Plan for group %s from %s (GitLab group %s, to be created)\n", group.Username, group.Source, group.GitLabPath)
+		case len(group.GitLabPath) > 0:
+			fmt.Printf("Plan for group %s from %s (GitLab group %s)\n", group.Username, group.Source, group.GitLabPath)
+		default:
+			fmt.Printf("Plan for group %s from %s (GitLab group %d)\n", group.Username, group.Source, group.GitLabGroupID)
+		}
+		fmt.Println("================================================")
+
+		if len(group.Error) > 0 {
+			fmt.Printf("Error: %s\n", group.Error)
+		}
+
+		for _, repo := range group.Repositories {
+			switch {
+			case len(repo.Error) > 0:
+				fmt.Printf("! %s: %s\n", repo.Name, repo.Error)
+				continue
+			case repo.Action == PlanActionSkip:
+				fmt.Printf("- %s: skip (%s)\n", repo.Name, repo.Reason)
+				continue
+			case repo.Action == PlanActionCreate:
+				fmt.Printf("+ %s: create project\n", repo.Name)
+			default:
+				fmt.Printf("~ %s: update project %d\n", repo.Name, *repo.ProjectID)
+			}
+
+			if repo.Wiki {
+				fmt.Println("  - Sync wiki")
+			}
+
+			for _, release := range repo.MissingReleases {
+				fmt.Printf("  - Create release %s\n", release.TagName)
+				for _, asset := range release.Assets {
+					size := "unknown size"
+					if asset.Size >= 0 {
+						size = utils.ConvertFromBytes(asset.Size)
+					}
+					fmt.Printf("    - %s asset %s (%s)\n", asset.Action, asset.Name, size)
+				}
+			}
+		}
+	}
+
+	fmt.Println("\n================================================")
+	fmt.Printf("Projects to create: %d\n", p.ProjectsToCreate)
+	fmt.Printf("Projects to update: %d\n", p.ProjectsToUpdate)
+	fmt.Printf("Repositories skipped: %d\n", p.Skipped)
+	fmt.Printf("Missing releases: %d\n", p.MissingReleases)
+	fmt.Printf("Asset bytes to transfer: %s\n", utils.ConvertFromBytes(p.AssetBytes))
+}
+
+func (p *Plan) Export(path string) error {
+	content, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, content, 0644)
+}
@@ -1,12 +1,18 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"log/slog"
 	"main/src/sources"
+	"main/src/utils"
 	"net/http"
 	"net/url"
 	"os"
@@ -28,21 +34,44 @@ type Project struct {
 	SourceRepository sources.SourceRepository
 
 	Repo *git.Repository
+
+	// Only limits the sync to a single part of the repository, empty to sync everything
+	Only string
+
+	Log *slog.Logger
+
+	// Result collects what the sync changed, for the run report
+	Result RepoResult
 }
 
 type ProjectGitLab struct {
-	ID                *int    `json:"id"`
-	Name              string  `json:"name"`
-	HttpUrl           *string `json:"http_url_to_repo"`
-	PathWithNamespace *string `json:"path_with_namespace"`
-	ParentGroupID     int
+	ID                *int     `json:"id"`
+	Name              string   `json:"name"`
+	Path              string   `json:"path"`
+	HttpUrl           *string  `json:"http_url_to_repo"`
+	PathWithNamespace *string  `json:"path_with_namespace"`
+	Mirror            bool     `json:"mirror"`
+	Archived          bool     `json:"archived"`
+	Topics            []string `json:"topics"`
+	Description       string   `json:"description"`
+	DefaultBranch     string   `json:"default_branch"`
+
+	BuildsAccessLevel        string `json:"builds_access_level"`
+	MergeRequestsAccessLevel string `json:"merge_requests_access_level"`
+	IssuesAccessLevel        string `json:"issues_access_level"`
+	PackagesEnabled          *bool  `json:"packages_enabled"`
+	Visibility               string `json:"visibility"`
+
+	ParentGroupID int
 }
 
-func NewProject(gitlab *GitLab, dufs *Dufs, groupId int, source sources.Source, username string, sourceRepository sources.SourceRepository, config ConfigRepo) *Project {
+// NewProject creates the project of a source repository, named name in GitLab.
+func NewProject(gitlab *GitLab, dufs *Dufs, groupId int, name string, source sources.Source, username string, sourceRepository sources.SourceRepository, config ConfigRepo) *Project {
 	return &Project{
 		Destination: gitlab,
 		DestinationRepository: &ProjectGitLab{
 			ID:            nil,
+			Name:          name,
 			HttpUrl:       nil,
 			ParentGroupID: groupId,
 		},
@@ -51,16 +80,27 @@ func NewProject(gitlab *GitLab, dufs *Dufs, groupId int, source sources.Source,
 		Source:             source,
 		SourceRepository:   sourceRepository,
 		Config:             config,
+		Log:                slog.With("source", source.ID(), "group", username, "repo", sourceRepository.Name),
+		Result: RepoResult{
+			Source:   source.ID(),
+			Username: username,
+			Name:     sourceRepository.Name,
+		},
 	}
 }
 
-func (g *Project) RetrieveExistingRepo() (int, error) {
+// ShouldSync reports whether the given part (OnlyGit, OnlyWiki or OnlyReleases) of the repository should be synced.
+func (g *Project) ShouldSync(part string) bool {
+	return len(g.Only) == 0 || g.Only == part
+}
+
+func (g *Project) RetrieveExistingRepo(ctx context.Context) (int, error) {
 	data := url.Values{}
-	data.Add("search", g.SourceRepository.Name)
+	data.Add("search", g.DestinationRepository.Name)
 	data.Add("per_page", "100")
 
 	urlPath := fmt.Sprintf("/api/v4/groups/%d/projects?%s", g.DestinationRepository.ParentGroupID, data.Encode())
-	body, err := g.Destination.Request(http.MethodGet, urlPath, nil)
+	body, err := g.Destination.Request(ctx, http.MethodGet, urlPath, nil)
 	if err != nil {
 		return -1, err
 	}
@@ -75,12 +115,27 @@ func (g *Project) RetrieveExistingRepo() (int, error) {
 		return -1, err
 	}
 
-	lowercaseRepoName := strings.ToLower(g.SourceRepository.Name)
+	lowercaseRepoName := strings.ToLower(g.DestinationRepository.Name)
 	for _, project := range projects {
 		if strings.ToLower(project.Name) == lowercaseRepoName {
+			// The project could be the backup of another repository with the same name
+			if err := g.checkOriginalURL(ctx, *project.ID); err != nil {
+				return -1, err
+			}
+
 			g.DestinationRepository.ID = project.ID
 			g.DestinationRepository.HttpUrl = project.HttpUrl
 			g.DestinationRepository.PathWithNamespace = project.PathWithNamespace
+			g.DestinationRepository.Mirror = project.Mirror
+			g.DestinationRepository.Archived = project.Archived
+			g.DestinationRepository.Topics = project.Topics
+			g.DestinationRepository.Description = project.Description
+			g.DestinationRepository.DefaultBranch = project.DefaultBranch
+			g.DestinationRepository.BuildsAccessLevel = project.BuildsAccessLevel
+			g.DestinationRepository.MergeRequestsAccessLevel = project.MergeRequestsAccessLevel
+			g.DestinationRepository.IssuesAccessLevel = project.IssuesAccessLevel
+			g.DestinationRepository.PackagesEnabled = project.PackagesEnabled
+			g.DestinationRepository.Visibility = project.Visibility
 
 			return *project.ID, nil
 		}
@@ -89,17 +144,35 @@ func (g *Project) RetrieveExistingRepo() (int, error) {
 	return -1, nil
 }
 
-func (g *Project) Import() (int, error) {
+// checkOriginalURL fails when the project is the backup of another repository, according to its "original_url" variable.
+func (g *Project) checkOriginalURL(ctx context.Context, projectID int) error {
+	originalURL, err := g.Destination.GetProjectVariable(ctx, projectID, "original_url")
+	if err != nil {
+		return err
+	}
+
+	if originalURL != nil && normalizeURL(*originalURL) != normalizeURL(g.SourceRepository.URL) {
+		return fmt.Errorf("project %s is the backup of %s", g.DestinationRepository.Name, *originalURL)
+	}
+
+	return nil
+}
+
+func (g *Project) Import(ctx context.Context) (int, error) {
 	data := url.Values{}
-	data.Add("name", g.SourceRepository.Name)
+	data.Add("name", g.DestinationRepository.Name)
 	data.Add("namespace_id", strconv.Itoa(g.DestinationRepository.ParentGroupID))
 	data.Add("import_url", g.SourceRepository.URL)
 
-	if g.SourceRepository.Description != nil {
-		data.Add("description", *g.SourceRepository.Description)
+	if description := projectDescription(g.SourceRepository); description != nil {
+		data.Add("description", *description)
+	}
+
+	if *g.Config.MirrorStrategy == MirrorGitLabPull {
+		data.Add("mirror", "true")
 	}
 
-	body, err := g.Destination.Request(http.MethodPost, "/api/v4/projects", []byte(data.Encode()))
+	body, err := g.Destination.Request(ctx, http.MethodPost, "/api/v4/projects", []byte(data.Encode()))
 	if err != nil {
 		return -1, fmt.Errorf("creating request: %w", err)
 	}
@@ -116,50 +189,101 @@ func (g *Project) Import() (int, error) {
 	g.DestinationRepository.ID = result.ID
 	g.DestinationRepository.HttpUrl = result.HttpUrl
 	g.DestinationRepository.PathWithNamespace = result.PathWithNamespace
+	g.DestinationRepository.Mirror = result.Mirror
+	g.DestinationRepository.Topics = result.Topics
+	g.DestinationRepository.Description = result.Description
+	g.DestinationRepository.DefaultBranch = result.DefaultBranch
+	g.DestinationRepository.BuildsAccessLevel = result.BuildsAccessLevel
+	g.DestinationRepository.MergeRequestsAccessLevel = result.MergeRequestsAccessLevel
+	g.DestinationRepository.IssuesAccessLevel = result.IssuesAccessLevel
+	g.DestinationRepository.PackagesEnabled = result.PackagesEnabled
+	g.DestinationRepository.Visibility = result.Visibility
 
 	return *result.ID, nil
 }
 
-func (g *Project) SetOriginalURL() error {
+func (g *Project) SetOriginalURL(ctx context.Context) error {
 	data := url.Values{}
 	data.Add("key", "original_url")
 	data.Add("value", g.SourceRepository.URL)
 
 	urlPath := fmt.Sprintf("/api/v4/projects/%d/variables", *g.DestinationRepository.ID)
-	_, err := g.Destination.Request(http.MethodPost, urlPath, []byte(data.Encode()))
+	_, err := g.Destination.Request(ctx, http.MethodPost, urlPath, []byte(data.Encode()))
 	return err
 }
 
-func (g *Project) LockUntilImport() error {
+// ImportError is returned when the GitLab importer did not bring the repository over.
+type ImportError struct {
+	Status  string
+	Message string
+}
+
+func (e *ImportError) Error() string {
+	if len(e.Message) == 0 {
+		return fmt.Sprintf("import %s", e.Status)
+	}
+
+	return fmt.Sprintf("import %s: %s", e.Status, e.Message)
+}
+
+// LockUntilImport polls the import status of the project until it finishes, fails or exceeds the import timeout.
+func (g *Project) LockUntilImport(ctx context.Context) error {
 	urlPath := fmt.Sprintf("/api/v4/projects/%d", *g.DestinationRepository.ID)
 
+	timeout := g.Destination.ImportTimeout
+	deadline := time.Now().Add(timeout)
+
 	for {
-		body, err := g.Destination.Request(http.MethodGet, urlPath, nil)
+		body, err := g.Destination.Request(ctx, http.MethodGet, urlPath, nil)
 		if err != nil {
 			return err
 		}
 
-		var result map[string]interface{}
+		if body.Status != http.StatusOK {
+			return fmt.Errorf("retrieving import status: status %d", body.Status)
+		}
+
+		var result struct {
+			ImportStatus *string `json:"import_status"`
+			ImportError  *string `json:"import_error"`
+		}
 		if err := json.Unmarshal(body.Body, &result); err != nil {
-			return err
+			return fmt.Errorf("parsing import status: %w", err)
+		}
+
+		if result.ImportStatus == nil {
+			return fmt.Errorf("project has no import status")
 		}
 
-		importStatus := result["import_status"].(string)
-		switch importStatus {
+		switch *result.ImportStatus {
 		case "finished":
 			return nil
 		case "failed":
-			return fmt.Errorf("current import status: %s", importStatus)
+			importErr := &ImportError{Status: "failed"}
+			if result.ImportError != nil {
+				importErr.Message = *result.ImportError
+			}
+			return importErr
+		case "none":
+			return &ImportError{Status: "not started"}
 		default:
-			fmt.Printf("- Current import status: %s\n", importStatus)
-			time.Sleep(5 * time.Second)
+			// "scheduled" and "started", or any state added by newer GitLab versions
+			g.Log.Debug("Waiting for import", "import_status", *result.ImportStatus)
+		}
+
+		if time.Now().After(deadline) {
+			return &ImportError{Status: "timed out", Message: fmt.Sprintf("still %s after %s", *result.ImportStatus, timeout)}
+		}
+
+		if err := utils.Sleep(ctx, g.Destination.PollInterval); err != nil {
+			return err
 		}
 	}
 }
 
-func (g *Project) GetProtectedBranches() ([]string, error) {
+func (g *Project) GetProtectedBranches(ctx context.Context) ([]string, error) {
 	urlPath := fmt.Sprintf("/api/v4/projects/%d/protected_branches", *g.DestinationRepository.ID)
-	body, err := g.Destination.Request(http.MethodGet, urlPath, nil)
+	body, err := g.Destination.Request(ctx, http.MethodGet, urlPath, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -179,19 +303,39 @@ func (g *Project) GetProtectedBranches() ([]string, error) {
 	return names, nil
 }
 
-func (g *Project) UnprotectBranch(name string) error {
+func (g *Project) UnprotectBranch(ctx context.Context, name string) error {
 	encodedBranch := url.QueryEscape(name)
 
 	urlPath := fmt.Sprintf("/api/v4/projects/%d/protected_branches/%s", *g.DestinationRepository.ID, encodedBranch)
-	_, err := g.Destination.Request(http.MethodDelete, urlPath, nil)
+	_, err := g.Destination.Request(ctx, http.MethodDelete, urlPath, nil)
 	return err
 }
 
-func (g *Project) CloneFromSource() error {
+// ProtectBranch protects the branches matching name, so that nobody can push or merge to them.
+func (g *Project) ProtectBranch(ctx context.Context, name string) error {
+	data := url.Values{}
+	data.Add("name", name)
+	data.Add("push_access_level", "0")
+	data.Add("merge_access_level", "0")
+
+	urlPath := fmt.Sprintf("/api/v4/projects/%d/protected_branches", *g.DestinationRepository.ID)
+	body, err := g.Destination.Request(ctx, http.MethodPost, urlPath, []byte(data.Encode()))
+	if err != nil {
+		return err
+	}
+
+	if body.Status != http.StatusCreated {
+		return fmt.Errorf("protecting branch %s: status %d", name, body.Status)
+	}
+
+	return nil
+}
+
+func (g *Project) CloneFromSource(ctx context.Context) error {
 	path := g.GetDir()
 	os.RemoveAll(path)
 
-	r, err := git.PlainClone(path, false, &git.CloneOptions{
+	r, err := git.PlainCloneContext(ctx, path, false, &git.CloneOptions{
 		URL: g.SourceRepository.URL,
 	})
 
@@ -208,18 +352,92 @@ func (g *Project) AddRemoteToRepo() error {
 		return fmt.Errorf("no repository found for project %d", *g.DestinationRepository.ID)
 	}
 
-	// Create url
-	parsedURL, _ := url.Parse(*g.DestinationRepository.HttpUrl)
-	parsedURL.User = url.UserPassword("oauth2", g.Destination.APIToken)
-
 	// Add a new remote, named "gitlab"
 	_, err := g.Repo.CreateRemote(&config.RemoteConfig{
 		Name: "gitlab",
-		URLs: []string{parsedURL.String()},
+		URLs: []string{g.GetDestinationURL()},
 	})
 	return err
 }
 
+// GetDestinationURL returns the GitLab repository url, including the credentials needed to push.
+func (g *Project) GetDestinationURL() string {
+	parsedURL, _ := url.Parse(*g.DestinationRepository.HttpUrl)
+	parsedURL.User = url.UserPassword("oauth2", g.Destination.APIToken)
+
+	return parsedURL.String()
+}
+
+// ListRefs lists the branches and tags of a remote repository, mapped to the hash they point to.
+func ListRefs(ctx context.Context, remoteURL string) (map[string]string, error) {
+	remote := git.NewRemote(memory.NewStorage(), &config.RemoteConfig{
+		Name: "origin",
+		URLs: []string{remoteURL},
+	})
+
+	refs, err := remote.ListContext(ctx, &git.ListOptions{})
+	if err != nil {
+		if errors.Is(err, transport.ErrEmptyRemoteRepository) {
+			return map[string]string{}, nil
+		}
+		return nil, err
+	}
+
+	result := make(map[string]string)
+	for _, ref := range refs {
+		if ref.Name().IsBranch() || ref.Name().IsTag() {
+			result[ref.Name().String()] = ref.Hash().String()
+		}
+	}
+
+	return result, nil
+}
+
+// RestoreTo pushes all the branches and tags of the GitLab backup to the target repository.
+func (g *Project) RestoreTo(ctx context.Context, targetURL string) error {
+	path := g.GetDir()
+	os.RemoveAll(path)
+
+	r, err := git.PlainInit(path, true)
+	if err != nil {
+		return err
+	}
+	g.Repo = r
+
+	refSpecs := []config.RefSpec{"+refs/heads/*:refs/heads/*", "+refs/tags/*:refs/tags/*"}
+
+	remote, err := r.CreateRemote(&config.RemoteConfig{
+		Name:  "gitlab",
+		URLs:  []string{g.GetDestinationURL()},
+		Fetch: refSpecs,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := remote.FetchContext(ctx, &git.FetchOptions{RefSpecs: refSpecs}); err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return fmt.Errorf("fetching from GitLab: %w", err)
+	}
+
+	_, err = r.CreateRemote(&config.RemoteConfig{
+		Name: "target",
+		URLs: []string{targetURL},
+	})
+	if err != nil {
+		return err
+	}
+
+	err = r.PushContext(ctx, &git.PushOptions{
+		RemoteName: "target",
+		RefSpecs:   refSpecs,
+	})
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return fmt.Errorf("pushing to target: %w", err)
+	}
+
+	return nil
+}
+
 func (g *Project) GetBranches() ([]string, error) {
 	if g.Repo == nil {
 		return nil, fmt.Errorf("no repository found for project %d", *g.DestinationRepository.ID)
@@ -236,13 +454,13 @@ func (g *Project) GetBranches() ([]string, error) {
 		return nil
 	})
 	if err != nil {
-		fmt.Printf("Error iterating branches: %v\n", err)
+		g.Log.Warn("Iterating branches", "error", err)
 	}
 
 	return names, nil
 }
 
-func (g *Project) PushBranch(name string) error {
+func (g *Project) PushBranch(ctx context.Context, name string) error {
 	if g.Repo == nil {
 		return fmt.Errorf("no repository found for project %d", *g.DestinationRepository.ID)
 	}
@@ -256,37 +474,61 @@ func (g *Project) PushBranch(name string) error {
 	}
 
 	// Perform the push
-	if err := g.Repo.Push(pushOptions); err != nil && err.Error() != "already up-to-date" {
+	if err := g.Repo.PushContext(ctx, pushOptions); err != nil && err.Error() != "already up-to-date" {
 		return err
 	}
 
 	return nil
 }
 
-func (g *Project) PushAllTags() error {
+// PushAllTags pushes every tag except the excluded ones.
+func (g *Project) PushAllTags(ctx context.Context, exclude map[plumbing.ReferenceName]bool) error {
 	if g.Repo == nil {
 		return fmt.Errorf("no repository found for project %d", *g.DestinationRepository.ID)
 	}
 
+	refSpecs := []config.RefSpec{"refs/tags/*:refs/tags/*"}
+	if len(exclude) > 0 {
+		tags, err := g.Repo.Tags()
+		if err != nil {
+			return err
+		}
+
+		refSpecs = make([]config.RefSpec, 0)
+		err = tags.ForEach(func(ref *plumbing.Reference) error {
+			if !exclude[ref.Name()] {
+				refSpecs = append(refSpecs, config.RefSpec(ref.Name().String()+":"+ref.Name().String()))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		if len(refSpecs) == 0 {
+			return nil
+		}
+	}
+
 	pushOptions := &git.PushOptions{
 		RemoteName: "gitlab",
-		RefSpecs:   []config.RefSpec{"refs/tags/*:refs/tags/*"},
+		RefSpecs:   refSpecs,
 		Force:      true,
 	}
 
 	// Perform the push
-	if err := g.Repo.Push(pushOptions); err != nil && err.Error() != "already up-to-date" {
+	if err := g.Repo.PushContext(ctx, pushOptions); err != nil && err.Error() != "already up-to-date" {
 		return err
 	}
 
 	return nil
 }
 
-func (g *Project) ReleaseExists(tagName string) (bool, error) {
+func (g *Project) ReleaseExists(ctx context.Context, tagName string) (bool, error) {
 	tagNameEncoded := url.QueryEscape(tagName)
 	urlPath := fmt.Sprintf("/api/v4/projects/%d/releases/%s", *g.DestinationRepository.ID, tagNameEncoded)
 
-	body, err := g.Destination.Request(http.MethodGet, urlPath, nil)
+	body, err := g.Destination.Request(ctx, http.MethodGet, urlPath, nil)
 	if err != nil {
 		return false, fmt.Errorf("creating request: %w", err)
 	}
@@ -294,7 +536,7 @@ func (g *Project) ReleaseExists(tagName string) (bool, error) {
 	return body.Status != http.StatusNotFound, nil
 }
 
-func (g *Project) CreateRelease(release sources.SourceRelease) error {
+func (g *Project) CreateRelease(ctx context.Context, release sources.SourceRelease) error {
 	data := url.Values{}
 	data.Add("name", release.Name)
 	data.Add("tag_name", release.TagName)
@@ -303,7 +545,7 @@ func (g *Project) CreateRelease(release sources.SourceRelease) error {
 
 	urlPath := fmt.Sprintf("/api/v4/projects/%d/releases", *g.DestinationRepository.ID)
 
-	body, err := g.Destination.Request(http.MethodPost, urlPath, []byte(data.Encode()))
+	body, err := g.Destination.Request(ctx, http.MethodPost, urlPath, []byte(data.Encode()))
 	if err != nil {
 		return fmt.Errorf("creating request: %w", err)
 	}
@@ -319,7 +561,7 @@ func (g *Project) GetDir() string {
 	return filepath.Join("/tmp/git-backup/", g.SourceUsername, g.SourceRepository.Name)
 }
 
-func (g *Project) LinkAsset(tagName, assetName, assetUrl string) error {
+func (g *Project) LinkAsset(ctx context.Context, tagName, assetName, assetUrl string) error {
 	encodedTagName := url.QueryEscape(tagName)
 
 	data := url.Values{}
@@ -328,7 +570,7 @@ func (g *Project) LinkAsset(tagName, assetName, assetUrl string) error {
 
 	urlPath := fmt.Sprintf("/api/v4/projects/%d/releases/%s/assets/links?%s", *g.DestinationRepository.ID, encodedTagName, data.Encode())
 
-	body, err := g.Destination.Request(http.MethodPost, urlPath, nil)
+	body, err := g.Destination.Request(ctx, http.MethodPost, urlPath, nil)
 	if err != nil {
 		return fmt.Errorf("creating request: %w", err)
 	}
@@ -357,9 +599,14 @@ func (g *Project) GetWikiProject() *Project {
 			URL:         g.Source.GetWikiURL(g.SourceUsername, g.SourceRepository.Name),
 			Description: nil,
 		},
+		Config: g.Config,
+		Log:    g.Log.With("wiki", true),
 	}
 }
 
 func (g *Project) Prune() {
 	os.RemoveAll(g.GetDir())
+
+	// Remove the directory of the user too, which only fails while other repositories are in it
+	os.Remove(filepath.Dir(g.GetDir()))
 }
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"main/src/utils"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// testProject returns project 1 of a test GitLab server answering the successive GET requests with responses,
+// repeating the last one.
+func testProject(t *testing.T, responses ...string) *Project {
+	t.Helper()
+
+	requests := 0
+	gitlab := testGitLab(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		_, _ = w.Write([]byte(responses[min(requests, len(responses)-1)]))
+		requests++
+	})
+	gitlab.PollInterval = time.Millisecond
+	gitlab.ImportTimeout = time.Second
+
+	return &Project{
+		Destination:           gitlab,
+		DestinationRepository: &ProjectGitLab{ID: utils.Pointer(1)},
+		Log:                   slog.Default(),
+	}
+}
+
+func TestLockUntilImport(t *testing.T) {
+	tests := []struct {
+		desc      string
+		responses []string
+		timeout   time.Duration
+		want      *ImportError
+	}{
+		{"finished", []string{`{"import_status": "finished"}`}, time.Second, nil},
+		{"finished after waiting", []string{`{"import_status": "scheduled"}`, `{"import_status": "started"}`, `{"import_status": "finished"}`}, time.Second, nil},
+		{"failed", []string{`{"import_status": "started"}`, `{"import_status": "failed", "import_error": "unreachable"}`}, time.Second, &ImportError{Status: "failed", Message: "unreachable"}},
+		{"none", []string{`{"import_status": "none"}`}, time.Second, &ImportError{Status: "not started"}},
+		{"timeout", []string{`{"import_status": "started"}`}, 20 * time.Millisecond, &ImportError{Status: "timed out"}},
+	}
+
+	for _, tt := range tests {
+		prj := testProject(t, tt.responses...)
+		prj.Destination.ImportTimeout = tt.timeout
+
+		err := prj.LockUntilImport(context.Background())
+		if tt.want == nil {
+			if err != nil {
+				t.Errorf("%s: unexpected error %v", tt.desc, err)
+			}
+			continue
+		}
+
+		var importErr *ImportError
+		if !errors.As(err, &importErr) {
+			t.Errorf("%s: error = %v, want an import error", tt.desc, err)
+			continue
+		}
+		if importErr.Status != tt.want.Status || (len(tt.want.Message) > 0 && importErr.Message != tt.want.Message) {
+			t.Errorf("%s: error = %v, want %v", tt.desc, importErr, tt.want)
+		}
+	}
+}
+
+func TestImportFallback(t *testing.T) {
+	importErr := &ImportError{Status: "failed"}
+
+	tests := []struct {
+		desc     string
+		err      error
+		enabled  bool
+		only     string
+		fallback bool
+	}{
+		{"import succeeded", nil, true, "", false},
+		{"import failed", importErr, true, "", true},
+		{"import timed out", &ImportError{Status: "timed out"}, true, "", true},
+		{"wrapped import error", errors.Join(errors.New("sync"), importErr), true, "", true},
+		{"fallback disabled", importErr, false, "", false},
+		{"other error", errors.New("connection refused"), true, "", false},
+		{"git data not synced", importErr, true, OnlyReleases, false},
+		{"only git data", importErr, true, OnlyGit, true},
+	}
+
+	for _, tt := range tests {
+		prj := &Project{Destination: &GitLab{ImportFallback: tt.enabled}, Only: tt.only}
+		if got := importFallback(prj, tt.err); got != tt.fallback {
+			t.Errorf("%s: importFallback = %t, want %t", tt.desc, got, tt.fallback)
+		}
+	}
+}
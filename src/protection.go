@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+const (
+	// ProtectionReprotect protects every branch against pushes and merges once the sync updated them
+	ProtectionReprotect = "reprotect"
+
+	// ProtectionUnprotect leaves the branches of the projects unprotected
+	ProtectionUnprotect = "unprotect"
+)
+
+// allBranches is the protected branch wildcard matching every branch.
+const allBranches = "*"
+
+// unprotectBranches removes the protections of the project, so the sync can force-push its branches.
+func unprotectBranches(ctx context.Context, prj *Project, log *slog.Logger) error {
+	protectedBranches, err := prj.GetProtectedBranches(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(protectedBranches) > 0 {
+		log.Info("Unprotecting branches", "count", len(protectedBranches))
+	}
+	for _, branch := range protectedBranches {
+		log.Debug("Unprotecting branch", "branch", branch)
+		if err := prj.UnprotectBranch(ctx, branch); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// withUnprotectedBranches runs fn with the branches of the project unprotected. With the reprotect
+// mode, every branch is protected again afterwards, even when fn failed or the sync was interrupted.
+func withUnprotectedBranches(ctx context.Context, prj *Project, log *slog.Logger, fn func() error) error {
+	if err := unprotectBranches(ctx, prj, log); err != nil {
+		return err
+	}
+
+	err := fn()
+
+	if *prj.Config.BranchProtection != ProtectionReprotect {
+		return err
+	}
+
+	// The backup must not stay writable because the sync was interrupted
+	log.Debug("Protecting branches")
+	if protectErr := prj.ProtectBranch(context.WithoutCancel(ctx), allBranches); protectErr != nil {
+		protectErr = fmt.Errorf("protecting branches: %w", protectErr)
+		if err == nil {
+			return protectErr
+		}
+		log.Error("Protecting branches", "error", protectErr)
+	}
+
+	return err
+}
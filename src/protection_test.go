@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"main/src/utils"
+	"net/http"
+	"slices"
+	"testing"
+)
+
+func TestWithUnprotectedBranches(t *testing.T) {
+	errPush := errors.New("push failed")
+
+	tests := []struct {
+		desc       string
+		protection string
+		fn         func(cancel context.CancelFunc) error
+		wantErr    error
+		reprotect  bool
+	}{
+		{"succeeded", ProtectionReprotect, func(context.CancelFunc) error { return nil }, nil, true},
+		{"failed", ProtectionReprotect, func(context.CancelFunc) error { return errPush }, errPush, true},
+		{"interrupted", ProtectionReprotect, func(cancel context.CancelFunc) error {
+			cancel()
+			return context.Canceled
+		}, context.Canceled, true},
+		{"unprotect mode", ProtectionUnprotect, func(context.CancelFunc) error { return errPush }, errPush, false},
+	}
+
+	for _, tt := range tests {
+		requests := make([]string, 0)
+		gitlab := testGitLab(t, func(w http.ResponseWriter, r *http.Request) {
+			requests = append(requests, r.Method+" "+r.URL.Path)
+
+			switch r.Method {
+			case http.MethodGet:
+				_, _ = w.Write([]byte(`[{"name": "main"}]`))
+			case http.MethodPost:
+				if r.FormValue("name") != allBranches {
+					t.Errorf("%s: protected %q, want %q", tt.desc, r.FormValue("name"), allBranches)
+				}
+				w.WriteHeader(http.StatusCreated)
+			default:
+				w.WriteHeader(http.StatusNoContent)
+			}
+		})
+
+		prj := &Project{
+			Destination:           gitlab,
+			DestinationRepository: &ProjectGitLab{ID: utils.Pointer(1)},
+			Config:                ConfigRepo{BranchProtection: utils.Pointer(tt.protection)},
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		err := withUnprotectedBranches(ctx, prj, slog.Default(), func() error { return tt.fn(cancel) })
+		cancel()
+
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: error = %v, want %v", tt.desc, err, tt.wantErr)
+		}
+
+		want := []string{
+			"GET /api/v4/projects/1/protected_branches",
+			"DELETE /api/v4/projects/1/protected_branches/main",
+		}
+		if tt.reprotect {
+			want = append(want, "POST /api/v4/projects/1/protected_branches")
+		}
+		if !slices.Equal(requests, want) {
+			t.Errorf("%s: requests = %v, want %v", tt.desc, requests, want)
+		}
+	}
+}
@@ -0,0 +1,364 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"main/src/sources"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+)
+
+const (
+	UpstreamDeletedReport  = "report"
+	UpstreamDeletedArchive = "archive"
+	UpstreamDeletedTopic   = "topic"
+	UpstreamDeletedRename  = "rename"
+
+	// upstreamDeletedTopic marks the projects whose source repository was deleted
+	upstreamDeletedTopic = "upstream-deleted"
+
+	// upstreamDeletedSuffix is appended to the name and path of the projects whose source repository was deleted
+	upstreamDeletedSuffix = "-upstream-deleted"
+)
+
+// ListGroupProjects lists the projects of a GitLab group, and of its subgroups with includeSubgroups.
+func (g *GitLab) ListGroupProjects(ctx context.Context, groupID int, includeSubgroups bool) ([]ProjectGitLab, error) {
+	projects := make([]ProjectGitLab, 0)
+
+	for page := 1; ; page++ {
+		urlPath := fmt.Sprintf("/api/v4/groups/%d/projects?per_page=100&page=%d&include_subgroups=%t", groupID, page, includeSubgroups)
+		body, err := g.Request(ctx, http.MethodGet, urlPath, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		if body.Status != http.StatusOK {
+			return nil, fmt.Errorf("listing group projects: status %d", body.Status)
+		}
+
+		var batch []ProjectGitLab
+		if err := json.Unmarshal(body.Body, &batch); err != nil {
+			return nil, err
+		}
+
+		if len(batch) == 0 {
+			return projects, nil
+		}
+		projects = append(projects, batch...)
+	}
+}
+
+// groupProject is a project of a GitLab group with its "original_url" variable, which is empty
+// for the projects created by something else than the backup.
+type groupProject struct {
+	ProjectGitLab
+	OriginalURL string
+}
+
+// listGroupProjects lists the projects of the GitLab group of groupCfg, and of its type subgroups, with
+// their "original_url" variable. resolveGroup must be called first, a missing group has no projects.
+func listGroupProjects(ctx context.Context, gitlab *GitLab, groupCfg ConfigGroup) ([]groupProject, error) {
+	if *groupCfg.GitLabGroupID < 0 {
+		return nil, nil
+	}
+
+	projects, err := gitlab.ListGroupProjects(ctx, *groupCfg.GitLabGroupID, *groupCfg.TypeSubgroups)
+	if err != nil {
+		return nil, fmt.Errorf("listing GitLab projects: %w", err)
+	}
+
+	result := make([]groupProject, 0, len(projects))
+	for _, project := range projects {
+		originalURL, err := gitlab.GetProjectVariable(ctx, *project.ID, "original_url")
+		if err != nil {
+			return nil, fmt.Errorf("retrieving original url of project %s: %w", project.Name, err)
+		}
+
+		owned := groupProject{ProjectGitLab: project}
+		if originalURL != nil {
+			owned.OriginalURL = *originalURL
+		}
+		result = append(result, owned)
+	}
+
+	return result, nil
+}
+
+// GetProjectVariable returns the value of a project variable, or nil when it does not exist.
+func (g *GitLab) GetProjectVariable(ctx context.Context, projectID int, key string) (*string, error) {
+	urlPath := fmt.Sprintf("/api/v4/projects/%d/variables/%s", projectID, url.PathEscape(key))
+	body, err := g.Request(ctx, http.MethodGet, urlPath, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if body.Status == http.StatusNotFound {
+		return nil, nil
+	}
+
+	if body.Status != http.StatusOK {
+		return nil, fmt.Errorf("retrieving variable %s: status %d", key, body.Status)
+	}
+
+	var variable struct {
+		Value string `json:"value"`
+	}
+	if err := json.Unmarshal(body.Body, &variable); err != nil {
+		return nil, err
+	}
+
+	return &variable.Value, nil
+}
+
+// SetProjectVariable updates the value of an existing project variable.
+func (g *GitLab) SetProjectVariable(ctx context.Context, projectID int, key, value string) error {
+	data := url.Values{}
+	data.Add("value", value)
+
+	urlPath := fmt.Sprintf("/api/v4/projects/%d/variables/%s", projectID, url.PathEscape(key))
+	body, err := g.Request(ctx, http.MethodPut, urlPath, []byte(data.Encode()))
+	if err != nil {
+		return err
+	}
+
+	if body.Status != http.StatusOK {
+		return fmt.Errorf("updating variable %s: status %d", key, body.Status)
+	}
+
+	return nil
+}
+
+// CreateProjectVariable adds a variable to a project.
+func (g *GitLab) CreateProjectVariable(ctx context.Context, projectID int, key, value string) error {
+	data := url.Values{}
+	data.Add("key", key)
+	data.Add("value", value)
+
+	urlPath := fmt.Sprintf("/api/v4/projects/%d/variables", projectID)
+	body, err := g.Request(ctx, http.MethodPost, urlPath, []byte(data.Encode()))
+	if err != nil {
+		return err
+	}
+
+	if body.Status != http.StatusCreated {
+		return fmt.Errorf("creating variable %s: status %d", key, body.Status)
+	}
+
+	return nil
+}
+
+// UpdateProject edits the attributes of a project.
+func (g *GitLab) UpdateProject(ctx context.Context, projectID int, data url.Values) error {
+	urlPath := fmt.Sprintf("/api/v4/projects/%d", projectID)
+	body, err := g.Request(ctx, http.MethodPut, urlPath, []byte(data.Encode()))
+	if err != nil {
+		return err
+	}
+
+	if body.Status != http.StatusOK {
+		return fmt.Errorf("updating project: status %d: %s", body.Status, body.Body)
+	}
+
+	return nil
+}
+
+func (g *GitLab) ArchiveProject(ctx context.Context, projectID int) error {
+	urlPath := fmt.Sprintf("/api/v4/projects/%d/archive", projectID)
+	body, err := g.Request(ctx, http.MethodPost, urlPath, nil)
+	if err != nil {
+		return err
+	}
+
+	if body.Status >= 300 {
+		return fmt.Errorf("archiving project: status %d", body.Status)
+	}
+
+	return nil
+}
+
+func (g *GitLab) UnarchiveProject(ctx context.Context, projectID int) error {
+	urlPath := fmt.Sprintf("/api/v4/projects/%d/unarchive", projectID)
+	body, err := g.Request(ctx, http.MethodPost, urlPath, nil)
+	if err != nil {
+		return err
+	}
+
+	if body.Status >= 300 {
+		return fmt.Errorf("unarchiving project: status %d", body.Status)
+	}
+
+	return nil
+}
+
+// normalizeURL makes clone urls comparable, ignoring the case and the ".git" suffix.
+func normalizeURL(cloneURL string) string {
+	return strings.TrimSuffix(strings.TrimSuffix(strings.ToLower(cloneURL), "/"), ".git")
+}
+
+// reconcileGroup compares the projects of the GitLab group with the complete list of upstream
+// repositories, mapped to their GitLab names. Projects of renamed repositories are renamed, and the
+// upstream_deleted action is applied to the projects of deleted ones. Projects without an
+// "original_url" variable are ignored.
+func reconcileGroup(ctx context.Context, gitlab *GitLab, groupCfg ConfigGroup, source sources.Source, projects []groupProject, upstream []sources.SourceRepository, upstreamNames map[string]string) {
+	log := slog.With("source", groupCfg.Source, "group", groupCfg.Username)
+	log.Info("Looking for repositories deleted or renamed upstream")
+
+	upstreamURLs := make(map[string]sources.SourceRepository)
+	for _, remote := range upstream {
+		upstreamURLs[normalizeURL(remote.URL)] = remote
+	}
+
+	names := make(map[string]bool)
+	for _, project := range projects {
+		names[strings.ToLower(project.Name)] = true
+	}
+
+	for _, project := range projects {
+		if ctx.Err() != nil {
+			return
+		}
+
+		log := log.With("project_id", *project.ID, "project", project.Name)
+		originalURL := project.OriginalURL
+
+		// Groups sharing a GitLab group must leave the projects of the others alone
+		if len(originalURL) == 0 || !ownedBy(originalURL, groupCfg.Source, groupCfg.Username) {
+			continue
+		}
+
+		if _, ok := upstreamURLs[normalizeURL(originalURL)]; ok {
+			continue
+		}
+
+		if resolver, ok := source.(sources.Resolver); ok {
+			current, err := resolver.ResolveURL(ctx, originalURL)
+			if err != nil {
+				// Better to retry on the next run than to act on a transient error
+				log.Warn("Resolving upstream repository", "url", originalURL, "error", err)
+				continue
+			}
+
+			if remote, ok := upstreamURLs[normalizeURL(current)]; ok && len(current) > 0 {
+				newName := upstreamNames[remote.URL]
+				if names[strings.ToLower(newName)] {
+					log.Warn("Repository was renamed upstream, but a project with the new name already exists", "new_name", newName)
+					continue
+				}
+
+				log.Info("Repository was renamed upstream, renaming project", "new_name", newName)
+				if err := renameProject(ctx, gitlab, project.ProjectGitLab, remote, newName); err != nil {
+					log.Error("Renaming project", "error", err)
+				}
+				names[strings.ToLower(newName)] = true
+				continue
+			}
+		}
+
+		// The repository is reported by its upstream name, as the project may have been renamed
+		action := *groupCfg.UpstreamDeleted
+		recorded := Status.DeletedRepo(groupCfg.Source, groupCfg.Username, path.Base(strings.TrimSuffix(originalURL, ".git")), action)
+
+		// Projects handled by a previous run are left as they are
+		if upstreamDeletedDone(project.ProjectGitLab, action) || (action == UpstreamDeletedReport && !recorded) {
+			continue
+		}
+
+		log.Warn("Repository was deleted upstream", "url", originalURL, "action", action)
+		if err := applyUpstreamDeleted(ctx, gitlab, project.ProjectGitLab, action); err != nil {
+			log.Error("Handling repository deleted upstream", "error", err)
+		}
+	}
+}
+
+// sourceHosts are the hosts of the clone urls of each source.
+var sourceHosts = map[string]string{
+	sources.GitHubID:      "github.com",
+	sources.HuggingFaceID: "huggingface.co",
+}
+
+// ownedBy reports whether the clone url is a repository of username on the source.
+func ownedBy(cloneURL, source, username string) bool {
+	parsed, err := url.Parse(cloneURL)
+	if err != nil || !strings.EqualFold(parsed.Host, sourceHosts[source]) {
+		return false
+	}
+
+	segments := strings.Split(strings.Trim(parsed.Path, "/"), "/")
+	if len(segments) == 3 && segments[0] == "datasets" {
+		segments = segments[1:]
+	}
+
+	return len(segments) == 2 && strings.EqualFold(segments[0], username)
+}
+
+// upstreamDeletedDone reports whether the upstream_deleted action was already applied to the project.
+func upstreamDeletedDone(project ProjectGitLab, action string) bool {
+	switch action {
+	case UpstreamDeletedArchive:
+		return project.Archived
+	case UpstreamDeletedTopic:
+		return slices.Contains(project.Topics, upstreamDeletedTopic)
+	case UpstreamDeletedRename:
+		return strings.HasSuffix(project.Name, upstreamDeletedSuffix)
+	}
+
+	return false
+}
+
+// renameProject follows the rename of the source repository, so the sync finds the project instead of importing a duplicate.
+func renameProject(ctx context.Context, gitlab *GitLab, project ProjectGitLab, remote sources.SourceRepository, name string) error {
+	data := url.Values{}
+	data.Add("name", name)
+	data.Add("path", name)
+	if project.Mirror {
+		data.Add("import_url", remote.URL)
+	}
+
+	if err := gitlab.UpdateProject(ctx, *project.ID, data); err != nil {
+		return err
+	}
+
+	if err := gitlab.SetProjectVariable(ctx, *project.ID, "original_url", remote.URL); err != nil {
+		return err
+	}
+
+	// The variable only exists for the projects whose name differs from the source name
+	originalName, err := gitlab.GetProjectVariable(ctx, *project.ID, "original_name")
+	if err != nil {
+		return err
+	}
+
+	if originalName != nil {
+		return gitlab.SetProjectVariable(ctx, *project.ID, "original_name", remote.Name)
+	} else if name != remote.Name {
+		return gitlab.CreateProjectVariable(ctx, *project.ID, "original_name", remote.Name)
+	}
+
+	return nil
+}
+
+func applyUpstreamDeleted(ctx context.Context, gitlab *GitLab, project ProjectGitLab, action string) error {
+	switch action {
+	case UpstreamDeletedArchive:
+		return gitlab.ArchiveProject(ctx, *project.ID)
+	case UpstreamDeletedTopic:
+		data := url.Values{}
+		for _, topic := range project.Topics {
+			data.Add("topics[]", topic)
+		}
+		data.Add("topics[]", upstreamDeletedTopic)
+		return gitlab.UpdateProject(ctx, *project.ID, data)
+	case UpstreamDeletedRename:
+		data := url.Values{}
+		data.Add("name", project.Name+upstreamDeletedSuffix)
+		data.Add("path", project.Path+upstreamDeletedSuffix)
+		return gitlab.UpdateProject(ctx, *project.ID, data)
+	}
+
+	return nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"main/src/sources"
+	"main/src/utils"
+	"net/http"
+	"slices"
+	"testing"
+)
+
+func TestRenameProject(t *testing.T) {
+	remote := sources.SourceRepository{Name: "new.name", URL: "https://github.com/alice/new.name.git"}
+
+	tests := []struct {
+		desc         string
+		name         string
+		originalName bool
+		want         []string
+	}{
+		{"same name", "new.name", false, []string{
+			"PUT /api/v4/projects/1",
+			"PUT /api/v4/projects/1/variables/original_url",
+			"GET /api/v4/projects/1/variables/original_name",
+		}},
+		{"mapped name", "new-name", false, []string{
+			"PUT /api/v4/projects/1",
+			"PUT /api/v4/projects/1/variables/original_url",
+			"GET /api/v4/projects/1/variables/original_name",
+			"POST /api/v4/projects/1/variables",
+		}},
+		{"previously mapped name", "new.name", true, []string{
+			"PUT /api/v4/projects/1",
+			"PUT /api/v4/projects/1/variables/original_url",
+			"GET /api/v4/projects/1/variables/original_name",
+			"PUT /api/v4/projects/1/variables/original_name",
+		}},
+	}
+
+	for _, tt := range tests {
+		requests := make([]string, 0)
+		gitlab := testGitLab(t, func(w http.ResponseWriter, r *http.Request) {
+			requests = append(requests, r.Method+" "+r.URL.Path)
+
+			switch {
+			case r.Method == http.MethodGet && !tt.originalName:
+				w.WriteHeader(http.StatusNotFound)
+			case r.Method == http.MethodGet:
+				_, _ = w.Write([]byte(`{"value": "old.name"}`))
+			case r.Method == http.MethodPost:
+				if r.FormValue("key") != "original_name" || r.FormValue("value") != remote.Name {
+					t.Errorf("%s: created variable %s=%s", tt.desc, r.FormValue("key"), r.FormValue("value"))
+				}
+				w.WriteHeader(http.StatusCreated)
+			default:
+				w.WriteHeader(http.StatusOK)
+			}
+		})
+
+		project := ProjectGitLab{ID: utils.Pointer(1), Name: "old.name"}
+		if err := renameProject(context.Background(), gitlab, project, remote, tt.name); err != nil {
+			t.Errorf("%s: %v", tt.desc, err)
+			continue
+		}
+
+		if !slices.Equal(requests, tt.want) {
+			t.Errorf("%s: requests = %v, want %v", tt.desc, requests, tt.want)
+		}
+	}
+}
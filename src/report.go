@@ -0,0 +1,231 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+const (
+	OutcomeCreated = "created"
+	OutcomeUpdated = "updated"
+	OutcomeSkipped = "skipped"
+	OutcomeFailed  = "failed"
+	OutcomeDeleted = "deleted"
+)
+
+// RepoResult is the outcome of a single repository in a run.
+type RepoResult struct {
+	Source   string `json:"source"`
+	Username string `json:"username"`
+	Name     string `json:"name"`
+
+	Outcome   string `json:"outcome"`
+	Reason    string `json:"reason,omitempty"`
+	Error     string `json:"error,omitempty"`
+	ProjectID *int   `json:"project_id,omitempty"`
+
+	ReleasesAdded  int     `json:"releases_added"`
+	AssetsUploaded int     `json:"assets_uploaded"`
+	AssetsLinked   int     `json:"assets_linked"`
+	RefsPreserved  int     `json:"refs_preserved"`
+	RefsDeleted    int     `json:"refs_deleted"`
+	Bytes          int64   `json:"bytes"`
+	Duration       float64 `json:"duration_seconds"`
+}
+
+// GroupFailure is an error that stopped a whole group before or while listing its repositories,
+// or the whole run when Source is empty.
+type GroupFailure struct {
+	Source   string `json:"source,omitempty"`
+	Username string `json:"username,omitempty"`
+	Error    string `json:"error"`
+}
+
+// Name identifies the failed group, or the run.
+func (f GroupFailure) Name() string {
+	if len(f.Source) == 0 {
+		return "run"
+	}
+
+	return fmt.Sprintf("%s/%s", f.Source, f.Username)
+}
+
+// RunReport holds the outcome of every repository evaluated in a run.
+type RunReport struct {
+	Run           RunStatus      `json:"run"`
+	Repositories  []RepoResult   `json:"repositories"`
+	GroupFailures []GroupFailure `json:"group_failures"`
+}
+
+// Failed counts the failed repositories and groups.
+func (r *RunReport) Failed() int {
+	failed := len(r.GroupFailures)
+	for _, repo := range r.Repositories {
+		if repo.Outcome == OutcomeFailed {
+			failed++
+		}
+	}
+
+	return failed
+}
+
+func (r *RunReport) WriteJSON(path string) error {
+	content, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return writeReportFile(path, content)
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     float64          `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      float64         `xml:"time,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      float64       `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+}
+
+// WriteJUnit writes the report as JUnit XML, with a test suite per group and a test case per repository.
+// A failed group gets a failed "(group)" test case.
+func (r *RunReport) WriteJUnit(path string) error {
+	content, err := r.junit()
+	if err != nil {
+		return err
+	}
+
+	return writeReportFile(path, content)
+}
+
+func (r *RunReport) junit() ([]byte, error) {
+	suites := make(map[string]*junitTestSuite)
+	names := make([]string, 0)
+
+	root := junitTestSuites{Name: fmt.Sprintf("git-backup run %d", r.Run.ID)}
+	suite := func(name string) *junitTestSuite {
+		suite, ok := suites[name]
+		if !ok {
+			suite = &junitTestSuite{Name: name}
+			suites[name] = suite
+			names = append(names, name)
+		}
+		return suite
+	}
+
+	for _, failure := range r.GroupFailures {
+		name := failure.Name()
+
+		suite := suite(name)
+		suite.TestCases = append(suite.TestCases, junitTestCase{
+			Name:      "(group)",
+			ClassName: name,
+			Failure:   &junitMessage{Message: failure.Error},
+		})
+		suite.Tests++
+		suite.Failures++
+		root.Tests++
+		root.Failures++
+	}
+
+	for _, repo := range r.Repositories {
+		name := fmt.Sprintf("%s/%s", repo.Source, repo.Username)
+		suite := suite(name)
+
+		testCase := junitTestCase{
+			Name:      repo.Name,
+			ClassName: name,
+			Time:      repo.Duration,
+			SystemOut: fmt.Sprintf("outcome=%s releases_added=%d assets_uploaded=%d assets_linked=%d refs_preserved=%d refs_deleted=%d bytes=%d",
+				repo.Outcome, repo.ReleasesAdded, repo.AssetsUploaded, repo.AssetsLinked, repo.RefsPreserved, repo.RefsDeleted, repo.Bytes),
+		}
+
+		switch repo.Outcome {
+		case OutcomeFailed:
+			testCase.Failure = &junitMessage{Message: repo.Error}
+			suite.Failures++
+			root.Failures++
+		case OutcomeSkipped:
+			testCase.Skipped = &junitMessage{Message: repo.Reason}
+			suite.Skipped++
+			root.Skipped++
+		case OutcomeDeleted:
+			testCase.Skipped = &junitMessage{Message: fmt.Sprintf("deleted upstream, action: %s", repo.Reason)}
+			suite.Skipped++
+			root.Skipped++
+		}
+
+		suite.TestCases = append(suite.TestCases, testCase)
+		suite.Tests++
+		suite.Time += repo.Duration
+		root.Tests++
+		root.Time += repo.Duration
+	}
+
+	sort.Strings(names)
+	for _, name := range names {
+		root.Suites = append(root.Suites, *suites[name])
+	}
+
+	content, err := xml.MarshalIndent(root, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte(xml.Header), content...), nil
+}
+
+// WriteReports writes the configured report files of a run.
+func WriteReports(config *Configuration, report *RunReport) {
+	if report == nil {
+		return
+	}
+
+	if path := config.Report.JSON; path != nil && len(*path) > 0 {
+		if err := report.WriteJSON(*path); err != nil {
+			slog.Error("Writing JSON report", "path", *path, "error", err)
+		}
+	}
+
+	if path := config.Report.JUnit; path != nil && len(*path) > 0 {
+		if err := report.WriteJUnit(*path); err != nil {
+			slog.Error("Writing JUnit report", "path", *path, "error", err)
+		}
+	}
+}
+
+func writeReportFile(path string, content []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, content, 0644)
+}
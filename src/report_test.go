@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testReport() *RunReport {
+	return &RunReport{
+		Run: RunStatus{ID: 7, Trigger: "sync"},
+		Repositories: []RepoResult{
+			{Source: "github", Username: "alice", Name: "created", Outcome: OutcomeCreated, ReleasesAdded: 2, RefsPreserved: 1, Duration: 1.5},
+			{Source: "github", Username: "alice", Name: "failed", Outcome: OutcomeFailed, Error: "push failed", Duration: 0.5},
+			{Source: "github", Username: "alice", Name: "skipped", Outcome: OutcomeSkipped, Reason: "from --skip"},
+			{Source: "huggingface", Username: "bob", Name: "deleted", Outcome: OutcomeDeleted, Reason: UpstreamDeletedArchive},
+		},
+		GroupFailures: []GroupFailure{
+			{Source: "github", Username: "carol", Error: "paginating repositories: status 500"},
+			{Error: "another run is in progress"},
+		},
+	}
+}
+
+func TestRunReportFailed(t *testing.T) {
+	if failed := testReport().Failed(); failed != 3 {
+		t.Errorf("Failed() = %d, want 3", failed)
+	}
+
+	if failed := (&RunReport{}).Failed(); failed != 0 {
+		t.Errorf("Failed() of an empty report = %d, want 0", failed)
+	}
+}
+
+func TestRunReportJUnit(t *testing.T) {
+	content, err := testReport().junit()
+	if err != nil {
+		t.Fatalf("junit: %v", err)
+	}
+
+	if !strings.HasPrefix(string(content), xml.Header) {
+		t.Errorf("missing XML header")
+	}
+
+	var root junitTestSuites
+	if err := xml.Unmarshal(content, &root); err != nil {
+		t.Fatalf("parsing JUnit report: %v", err)
+	}
+
+	if root.Name != "git-backup run 7" || root.Tests != 6 || root.Failures != 3 || root.Skipped != 2 || root.Time != 2 {
+		t.Errorf("root = %s tests=%d failures=%d skipped=%d time=%g, want git-backup run 7 tests=6 failures=3 skipped=2 time=2",
+			root.Name, root.Tests, root.Failures, root.Skipped, root.Time)
+	}
+
+	tests := []struct {
+		suite    string
+		tests    int
+		failures int
+		skipped  int
+	}{
+		{"github/alice", 3, 1, 1},
+		{"github/carol", 1, 1, 0},
+		{"huggingface/bob", 1, 0, 1},
+		{"run", 1, 1, 0},
+	}
+
+	if len(root.Suites) != len(tests) {
+		t.Fatalf("got %d suites, want %d", len(root.Suites), len(tests))
+	}
+	for i, tt := range tests {
+		suite := root.Suites[i]
+		if suite.Name != tt.suite || suite.Tests != tt.tests || suite.Failures != tt.failures || suite.Skipped != tt.skipped {
+			t.Errorf("suite %d = %s tests=%d failures=%d skipped=%d, want %s tests=%d failures=%d skipped=%d",
+				i, suite.Name, suite.Tests, suite.Failures, suite.Skipped, tt.suite, tt.tests, tt.failures, tt.skipped)
+		}
+	}
+
+	alice := root.Suites[0].TestCases
+	if alice[0].SystemOut != "outcome=created releases_added=2 assets_uploaded=0 assets_linked=0 refs_preserved=1 refs_deleted=0 bytes=0" {
+		t.Errorf("system-out = %q", alice[0].SystemOut)
+	}
+	if alice[1].Failure == nil || alice[1].Failure.Message != "push failed" {
+		t.Errorf("failure = %+v, want push failed", alice[1].Failure)
+	}
+	if alice[2].Skipped == nil || alice[2].Skipped.Message != "from --skip" {
+		t.Errorf("skipped = %+v, want from --skip", alice[2].Skipped)
+	}
+
+	carol := root.Suites[1].TestCases[0]
+	if carol.Name != "(group)" || carol.Failure == nil || carol.Failure.Message != "paginating repositories: status 500" {
+		t.Errorf("group test case = %+v", carol)
+	}
+
+	deleted := root.Suites[2].TestCases[0]
+	if deleted.Skipped == nil || deleted.Skipped.Message != "deleted upstream, action: archive" {
+		t.Errorf("deleted = %+v", deleted.Skipped)
+	}
+}
+
+func TestRunReportJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "reports", "report.json")
+	if err := testReport().WriteJSON(path); err != nil {
+		t.Fatalf("WriteJSON: %v", err)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var report struct {
+		Run struct {
+			ID      int    `json:"id"`
+			Trigger string `json:"trigger"`
+		} `json:"run"`
+		Repositories  []map[string]any `json:"repositories"`
+		GroupFailures []map[string]any `json:"group_failures"`
+	}
+	if err := json.Unmarshal(content, &report); err != nil {
+		t.Fatalf("parsing JSON report: %v", err)
+	}
+
+	if report.Run.ID != 7 || report.Run.Trigger != "sync" {
+		t.Errorf("run = %+v", report.Run)
+	}
+	if len(report.Repositories) != 4 || len(report.GroupFailures) != 2 {
+		t.Fatalf("got %d repositories and %d group failures, want 4 and 2", len(report.Repositories), len(report.GroupFailures))
+	}
+
+	created := report.Repositories[0]
+	if created["outcome"] != OutcomeCreated || created["releases_added"] != 2.0 || created["duration_seconds"] != 1.5 {
+		t.Errorf("created = %v", created)
+	}
+	if _, ok := created["error"]; ok {
+		t.Errorf("error is set on a successful repository: %v", created)
+	}
+
+	if run := report.GroupFailures[1]; run["error"] != "another run is in progress" || run["source"] != nil {
+		t.Errorf("run failure = %v", run)
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+const (
+	// RewriteBackup keeps the previous tip of a rewritten ref under refs/backup/<timestamp>/ before overwriting it
+	RewriteBackup = "backup"
+
+	// RewriteRefuse leaves rewritten refs untouched in GitLab and fails the repository
+	RewriteRefuse = "refuse"
+
+	// RewriteForce overwrites rewritten refs, losing their previous history
+	RewriteForce = "force"
+)
+
+// destinationPrefix is where the refs of the GitLab project are fetched, to compare them with the source.
+const destinationPrefix = "refs/remotes/gitlab/"
+
+// RewrittenRef is a GitLab ref that the push would overwrite with a commit not descending from it.
+type RewrittenRef struct {
+	Name plumbing.ReferenceName
+	Old  plumbing.Hash
+	New  plumbing.Hash
+}
+
+// FetchDestination fetches the branches and tags of the GitLab project under refs/remotes/gitlab/.
+func (g *Project) FetchDestination(ctx context.Context) error {
+	err := g.Repo.FetchContext(ctx, &git.FetchOptions{
+		RemoteName: "gitlab",
+		RefSpecs: []config.RefSpec{
+			config.RefSpec("+refs/heads/*:" + destinationPrefix + "heads/*"),
+			config.RefSpec("+refs/tags/*:" + destinationPrefix + "tags/*"),
+		},
+		Tags: git.NoTags,
+	})
+	if errors.Is(err, git.NoErrAlreadyUpToDate) || errors.Is(err, transport.ErrEmptyRemoteRepository) {
+		return nil
+	}
+
+	return err
+}
+
+// RewrittenRefs returns the refs whose GitLab version would be lost by pushing the local one: branches
+// that cannot be fast-forwarded and tags that point elsewhere. FetchDestination must be called first.
+func (g *Project) RewrittenRefs(names []plumbing.ReferenceName) ([]RewrittenRef, error) {
+	rewritten := make([]RewrittenRef, 0)
+
+	for _, name := range names {
+		local, err := g.Repo.Reference(name, true)
+		if err != nil {
+			return nil, err
+		}
+
+		remote, err := g.Repo.Reference(destinationRef(name), true)
+		if errors.Is(err, plumbing.ErrReferenceNotFound) {
+			// New in the source
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		if local.Hash() == remote.Hash() {
+			continue
+		}
+
+		if name.IsBranch() {
+			oldCommit, err := g.Repo.CommitObject(remote.Hash())
+			if err != nil {
+				return nil, err
+			}
+
+			newCommit, err := g.Repo.CommitObject(local.Hash())
+			if err != nil {
+				return nil, err
+			}
+
+			fastForward, err := oldCommit.IsAncestor(newCommit)
+			if err != nil {
+				return nil, err
+			}
+			if fastForward {
+				continue
+			}
+		}
+
+		rewritten = append(rewritten, RewrittenRef{Name: name, Old: remote.Hash(), New: local.Hash()})
+	}
+
+	return rewritten, nil
+}
+
+// BackupRefs pushes the GitLab version of the rewritten refs to refs/backup/<timestamp>/.
+func (g *Project) BackupRefs(ctx context.Context, refs []RewrittenRef, timestamp string) error {
+	refSpecs := make([]config.RefSpec, 0, len(refs))
+	for _, ref := range refs {
+		backup := fmt.Sprintf("refs/backup/%s/%s", timestamp, strings.TrimPrefix(ref.Name.String(), "refs/"))
+		refSpecs = append(refSpecs, config.RefSpec(destinationRef(ref.Name).String()+":"+backup))
+	}
+
+	err := g.Repo.PushContext(ctx, &git.PushOptions{
+		RemoteName: "gitlab",
+		RefSpecs:   refSpecs,
+	})
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return err
+	}
+
+	return nil
+}
+
+func destinationRef(name plumbing.ReferenceName) plumbing.ReferenceName {
+	return plumbing.ReferenceName(destinationPrefix + strings.TrimPrefix(name.String(), "refs/"))
+}
+
+// guardRewrites applies the rewrite policy to the branches and tags about to be pushed, and returns the refs that must not be pushed.
+func guardRewrites(ctx context.Context, prj *Project, log *slog.Logger, branches []string) (map[plumbing.ReferenceName]bool, error) {
+	policy := *prj.Config.RewritePolicy
+	if policy == RewriteForce {
+		return nil, nil
+	}
+
+	log.Debug("Fetching GitLab refs to detect rewritten history")
+	if err := prj.FetchDestination(ctx); err != nil {
+		return nil, fmt.Errorf("fetching GitLab refs: %w", err)
+	}
+
+	names := make([]plumbing.ReferenceName, 0, len(branches))
+	for _, branch := range branches {
+		names = append(names, branchRef(branch))
+	}
+
+	tags, err := prj.Repo.Tags()
+	if err != nil {
+		return nil, err
+	}
+	err = tags.ForEach(func(ref *plumbing.Reference) error {
+		names = append(names, ref.Name())
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rewritten, err := prj.RewrittenRefs(names)
+	if err != nil {
+		return nil, fmt.Errorf("comparing with GitLab refs: %w", err)
+	}
+
+	if len(rewritten) == 0 {
+		return nil, nil
+	}
+
+	if policy == RewriteRefuse {
+		skip := make(map[plumbing.ReferenceName]bool)
+		for _, ref := range rewritten {
+			log.Warn("Refusing to overwrite rewritten ref", "ref", ref.Name.String(), "old", ref.Old.String(), "new", ref.New.String())
+			skip[ref.Name] = true
+		}
+		return skip, nil
+	}
+
+	timestamp := time.Now().UTC().Format("20060102T150405Z")
+	for _, ref := range rewritten {
+		log.Warn("Preserving rewritten ref", "ref", ref.Name.String(), "old", ref.Old.String(), "new", ref.New.String(), "backup", "refs/backup/"+timestamp)
+	}
+
+	if err := prj.BackupRefs(ctx, rewritten, timestamp); err != nil {
+		return nil, fmt.Errorf("preserving rewritten refs: %w", err)
+	}
+	prj.Result.RefsPreserved += len(rewritten)
+
+	return nil, nil
+}
+
+// branchRef returns the reference of a branch, given either its short or full name.
+func branchRef(branch string) plumbing.ReferenceName {
+	if strings.HasPrefix(branch, "refs/heads/") {
+		return plumbing.ReferenceName(branch)
+	}
+
+	return plumbing.NewBranchReferenceName(branch)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"slices"
+	"testing"
+	"time"
+)
+
+// testRepository returns an in-memory repository and a function committing on top of a parent, or of
+// the current HEAD when parent is zero.
+func testRepository(t *testing.T) (*git.Repository, func(parent plumbing.Hash, message string) plumbing.Hash) {
+	t.Helper()
+
+	repo, err := git.Init(memory.NewStorage(), memfs.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	worktree, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	commit := func(parent plumbing.Hash, message string) plumbing.Hash {
+		t.Helper()
+
+		if !parent.IsZero() {
+			if err := worktree.Checkout(&git.CheckoutOptions{Hash: parent, Force: true}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		hash, err := worktree.Commit(message, &git.CommitOptions{
+			AllowEmptyCommits: true,
+			Author:            &object.Signature{Name: "test", Email: "test@example.com", When: time.Unix(0, 0)},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hash
+	}
+
+	return repo, commit
+}
+
+func setRef(t *testing.T, repo *git.Repository, name string, hash plumbing.Hash) {
+	t.Helper()
+
+	if err := repo.Storer.SetReference(plumbing.NewHashReference(plumbing.ReferenceName(name), hash)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRewrittenRefs(t *testing.T) {
+	repo, commit := testRepository(t)
+
+	first := commit(plumbing.ZeroHash, "first")
+	second := commit(plumbing.ZeroHash, "second")
+	diverged := commit(first, "diverged")
+
+	refs := []struct {
+		name        string
+		local       plumbing.Hash
+		destination plumbing.Hash
+	}{
+		{"heads/fast-forward", second, first},
+		{"heads/same", first, first},
+		{"heads/new", first, plumbing.ZeroHash},
+		{"heads/force-pushed", diverged, second},
+		{"heads/reset", first, second},
+		{"tags/moved", first, second},
+		{"tags/same", second, second},
+	}
+
+	names := make([]plumbing.ReferenceName, 0, len(refs))
+	for _, ref := range refs {
+		setRef(t, repo, "refs/"+ref.name, ref.local)
+		if !ref.destination.IsZero() {
+			setRef(t, repo, destinationPrefix+ref.name, ref.destination)
+		}
+		names = append(names, plumbing.ReferenceName("refs/"+ref.name))
+	}
+
+	prj := &Project{Repo: repo}
+	rewritten, err := prj.RewrittenRefs(names)
+	if err != nil {
+		t.Fatalf("RewrittenRefs: %v", err)
+	}
+
+	want := []RewrittenRef{
+		{Name: "refs/heads/force-pushed", Old: second, New: diverged},
+		{Name: "refs/heads/reset", Old: second, New: first},
+		{Name: "refs/tags/moved", Old: second, New: first},
+	}
+	if !slices.Equal(rewritten, want) {
+		t.Errorf("RewrittenRefs = %v, want %v", rewritten, want)
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// projectSettingsChanges returns the attributes of the project that differ from the project_settings.
+func projectSettingsChanges(settings ConfigProjectSettings, project *ProjectGitLab) url.Values {
+	data := url.Values{}
+
+	if settings.JobsEnabled != nil && project.BuildsAccessLevel != accessLevel(*settings.JobsEnabled) {
+		data.Add("builds_access_level", accessLevel(*settings.JobsEnabled))
+	}
+
+	if settings.MergeRequestsAccessLevel != nil && project.MergeRequestsAccessLevel != *settings.MergeRequestsAccessLevel {
+		data.Add("merge_requests_access_level", *settings.MergeRequestsAccessLevel)
+	}
+
+	if settings.IssuesEnabled != nil && project.IssuesAccessLevel != accessLevel(*settings.IssuesEnabled) {
+		data.Add("issues_access_level", accessLevel(*settings.IssuesEnabled))
+	}
+
+	if settings.PackagesEnabled != nil && (project.PackagesEnabled == nil || *project.PackagesEnabled != *settings.PackagesEnabled) {
+		data.Add("packages_enabled", strconv.FormatBool(*settings.PackagesEnabled))
+	}
+
+	if settings.Visibility != nil && project.Visibility != *settings.Visibility {
+		data.Add("visibility", *settings.Visibility)
+	}
+
+	return data
+}
+
+// sortedKeys lists the attributes of an update, for logging.
+func sortedKeys(values url.Values) string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return strings.Join(keys, ",")
+}
+
+func accessLevel(enabled bool) string {
+	if enabled {
+		return "enabled"
+	}
+
+	return "disabled"
+}
+
+// applyProjectSettings updates the project to match the project_settings. An archived project is
+// unarchived first when its archived state is managed, as it could not be updated otherwise; it is
+// archived again by archiveProject once synced.
+func applyProjectSettings(ctx context.Context, prj *Project, log *slog.Logger) error {
+	if archivedState(prj) != nil && prj.DestinationRepository.Archived {
+		log.Info("Unarchiving project to sync it")
+		if err := prj.Destination.UnarchiveProject(ctx, *prj.DestinationRepository.ID); err != nil {
+			return err
+		}
+		prj.DestinationRepository.Archived = false
+	}
+
+	if changes := projectSettingsChanges(prj.Config.ProjectSettings, prj.DestinationRepository); len(changes) > 0 {
+		log.Info("Updating project settings", "settings", sortedKeys(changes))
+		if err := prj.Destination.UpdateProject(ctx, *prj.DestinationRepository.ID, changes); err != nil {
+			return fmt.Errorf("updating project settings: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// archivedState returns whether the project should be archived: as set in the project_settings, or else
+// as the source repository. It is nil when neither tells.
+func archivedState(prj *Project) *bool {
+	if prj.Config.ProjectSettings.Archived != nil {
+		return prj.Config.ProjectSettings.Archived
+	}
+
+	return prj.SourceRepository.Archived
+}
+
+// archiveProject archives the project once synced, when the project_settings or the source require it.
+func archiveProject(ctx context.Context, prj *Project, log *slog.Logger) {
+	archived := archivedState(prj)
+	if archived == nil || !*archived || prj.DestinationRepository.ID == nil {
+		return
+	}
+
+	// Even an interrupted sync must leave the project archived
+	log.Debug("Archiving project")
+	if err := prj.Destination.ArchiveProject(context.WithoutCancel(ctx), *prj.DestinationRepository.ID); err != nil {
+		log.Error("Archiving project", "error", err)
+		return
+	}
+	prj.DestinationRepository.Archived = true
+}
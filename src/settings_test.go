@@ -0,0 +1,57 @@
+package main
+
+import (
+	"main/src/utils"
+	"net/url"
+	"testing"
+)
+
+func TestProjectSettingsChanges(t *testing.T) {
+	project := &ProjectGitLab{
+		BuildsAccessLevel:        "enabled",
+		MergeRequestsAccessLevel: "enabled",
+		IssuesAccessLevel:        "disabled",
+		PackagesEnabled:          utils.Pointer(true),
+		Visibility:               "private",
+	}
+
+	tests := []struct {
+		desc     string
+		settings ConfigProjectSettings
+		project  *ProjectGitLab
+		want     url.Values
+	}{
+		{"unmanaged", ConfigProjectSettings{}, project, url.Values{}},
+		{"unchanged", ConfigProjectSettings{
+			JobsEnabled:              utils.Pointer(true),
+			MergeRequestsAccessLevel: utils.Pointer("enabled"),
+			IssuesEnabled:            utils.Pointer(false),
+			PackagesEnabled:          utils.Pointer(true),
+			Visibility:               utils.Pointer("private"),
+		}, project, url.Values{}},
+		{"all changed", ConfigProjectSettings{
+			JobsEnabled:              utils.Pointer(false),
+			MergeRequestsAccessLevel: utils.Pointer("private"),
+			IssuesEnabled:            utils.Pointer(true),
+			PackagesEnabled:          utils.Pointer(false),
+			Visibility:               utils.Pointer("internal"),
+		}, project, url.Values{
+			"builds_access_level":         {"disabled"},
+			"merge_requests_access_level": {"private"},
+			"issues_access_level":         {"enabled"},
+			"packages_enabled":            {"false"},
+			"visibility":                  {"internal"},
+		}},
+		{"unknown packages state", ConfigProjectSettings{PackagesEnabled: utils.Pointer(true)}, &ProjectGitLab{}, url.Values{
+			"packages_enabled": {"true"},
+		}},
+		{"archived is not a setting", ConfigProjectSettings{Archived: utils.Pointer(true)}, project, url.Values{}},
+	}
+
+	for _, tt := range tests {
+		got := projectSettingsChanges(tt.settings, tt.project)
+		if got.Encode() != tt.want.Encode() {
+			t.Errorf("%s: changes = %s, want %s", tt.desc, got.Encode(), tt.want.Encode())
+		}
+	}
+}
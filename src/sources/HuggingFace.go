@@ -1,53 +1,65 @@
 package sources
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"io"
+	"main/src/utils"
 	"net/http"
 	"regexp"
 	"strings"
 )
 
+// huggingFaceDone marks the end of the listing, once the last page has no link to a next one
+const huggingFaceDone = "done"
+
 type HuggingFace struct {
-	Token string
+	// BaseURL is the address of the Hugging Face API
+	BaseURL string
+	Token   string
+	Cache   *utils.HTTPCache
 }
 
 type HuggingFaceRepository struct {
 	ID string `json:"id"`
 }
 
-type HuggingFaceMetadata struct {
-	What string
+func NewHuggingFace(token string, cache *utils.HTTPCache) *HuggingFace {
+	return &HuggingFace{BaseURL: "https://huggingface.co", Token: token, Cache: cache}
 }
 
-func NewHuggingFace(token string) *HuggingFace {
-	return &HuggingFace{Token: token}
+func (g *HuggingFace) ID() string {
+	return HuggingFaceID
 }
 
-func (g *HuggingFace) Paginate(username string, prev *PaginationResponse) (*PaginationResponse, error) {
+func (g *HuggingFace) Paginate(ctx context.Context, username string, prev *PaginationResponse) (*PaginationResponse, error) {
 	if prev == nil {
 		prev = &PaginationResponse{
-			Metadata: HuggingFaceMetadata{
-				What: "models",
+			Metadata: map[string]string{
+				"what": "models",
 			},
 		}
 	}
 
-	meta := prev.Metadata.(HuggingFaceMetadata)
+	meta := map[string]string{"what": prev.Metadata["what"]}
+
+	// The listing is complete
+	if meta["what"] == huggingFaceDone {
+		return &PaginationResponse{Metadata: meta}, nil
+	}
 
 	urlPath := ""
-	if meta.What == "models" {
-		urlPath = fmt.Sprintf("https://huggingface.co/api/models?author=%s&limit=100", username)
-	} else if meta.What == "datasets" {
-		urlPath = fmt.Sprintf("https://huggingface.co/api/datasets?author=%s&limit=100", username)
+	if meta["what"] == "models" {
+		urlPath = fmt.Sprintf("%s/api/models?author=%s&limit=100", g.BaseURL, username)
+	} else if meta["what"] == "datasets" {
+		urlPath = fmt.Sprintf("%s/api/datasets?author=%s&limit=100", g.BaseURL, username)
 	}
 
 	if prev.NextCursor != nil {
 		urlPath = *prev.NextCursor
 	}
 
-	req, err := http.NewRequest(http.MethodGet, urlPath, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, urlPath, nil)
 	if err != nil {
 		return nil, fmt.Errorf("error creating request: %v", err)
 	}
@@ -56,43 +68,53 @@ func (g *HuggingFace) Paginate(username string, prev *PaginationResponse) (*Pagi
 		req.Header.Set("Authorization", "Bearer "+g.Token)
 	}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := g.Cache.Do(req, utils.DoRequest)
 	if err != nil {
 		return nil, fmt.Errorf("error making request: %v", err)
 	}
-	defer resp.Body.Close()
 
 	nextCursor := extractLink(resp.Header.Get("Link"))
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("received non-200 status code: %d", resp.StatusCode)
-	}
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("error reading response body: %v", err)
+	if resp.Status != http.StatusOK {
+		return nil, fmt.Errorf("received non-200 status code: %d", resp.Status)
 	}
+	body := resp.Body
 
 	githubRepos := make([]HuggingFaceRepository, 0)
 	if err := json.Unmarshal(body, &githubRepos); err != nil {
 		return nil, fmt.Errorf("error decoding JSON to map: %v", err)
 	}
 
+	// Datasets are cloned from a separate namespace
+	repoType, urlPrefix := TypeModel, "https://huggingface.co/"
+	if meta["what"] == "datasets" {
+		repoType, urlPrefix = TypeDataset, "https://huggingface.co/datasets/"
+	}
+
 	repos := make([]SourceRepository, 0)
 	for _, repo := range githubRepos {
 		repos = append(repos, SourceRepository{
 			Name:        strings.Split(repo.ID, "/")[1],
 			Description: nil,
-			URL:         fmt.Sprintf("https://huggingface.co/%s.git", repo.ID),
+			URL:         fmt.Sprintf("%s%s.git", urlPrefix, repo.ID),
+			Type:        repoType,
 		})
 	}
 
 	// If finished models, go to datasets
-	if len(repos) == 0 && meta.What == "models" {
-		meta.What = "datasets"
-		prev.Metadata = meta
-		return g.Paginate(username, prev)
+	if len(repos) == 0 && meta["what"] == "models" {
+		meta["what"] = "datasets"
+		return g.Paginate(ctx, username, &PaginationResponse{Metadata: meta})
+	}
+
+	// After the last page of models, the next one is the first page of datasets
+	if len(nextCursor) == 0 {
+		if meta["what"] == "models" {
+			meta["what"] = "datasets"
+		} else {
+			meta["what"] = huggingFaceDone
+		}
+		return &PaginationResponse{Repositories: repos, Metadata: meta}, nil
 	}
 
 	return &PaginationResponse{
@@ -106,7 +128,7 @@ func (g *HuggingFace) GetWikiURL(username, repoName string) string {
 	return ""
 }
 
-func (g *HuggingFace) FetchReleases(username, repoName string) ([]SourceRelease, error) {
+func (g *HuggingFace) FetchReleases(ctx context.Context, username, repoName string) ([]SourceRelease, error) {
 	return nil, nil
 }
 
@@ -0,0 +1,54 @@
+package sources
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHuggingFacePaginate(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/api/models" && r.URL.Query().Get("page") == "":
+			w.Header().Set("Link", fmt.Sprintf(`<%s/api/models?author=user&page=2>; rel="next"`, server.URL))
+			fmt.Fprint(w, `[{"id": "user/model-1"}]`)
+		case r.URL.Path == "/api/models":
+			fmt.Fprint(w, `[{"id": "user/model-2"}]`)
+		case r.URL.Path == "/api/datasets":
+			fmt.Fprint(w, `[{"id": "user/dataset-1"}]`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	source := NewHuggingFace("", nil)
+	source.BaseURL = server.URL
+
+	var repos []SourceRepository
+	result, err := source.Paginate(context.Background(), "user", nil)
+	for ; err == nil && len(result.Repositories) > 0; result, err = source.Paginate(context.Background(), "user", result) {
+		repos = append(repos, result.Repositories...)
+	}
+
+	if err != nil {
+		t.Fatalf("Paginate: %v", err)
+	}
+
+	want := []struct{ name, typ string }{
+		{"model-1", TypeModel},
+		{"model-2", TypeModel},
+		{"dataset-1", TypeDataset},
+	}
+	if len(repos) != len(want) {
+		t.Fatalf("got %d repositories, want %d: %+v", len(repos), len(want), repos)
+	}
+	for i, w := range want {
+		if repos[i].Name != w.name || repos[i].Type != w.typ {
+			t.Errorf("repository %d = %s (%s), want %s (%s)", i, repos[i].Name, repos[i].Type, w.name, w.typ)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package sources
+
+import (
+	"context"
+	"log/slog"
+	"main/src/metrics"
+	"main/src/utils"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimit tracks the request budget reported by an API in the X-RateLimit-* headers.
+type RateLimit struct {
+	mu        sync.Mutex
+	known     bool
+	limit     int
+	remaining int
+	reset     time.Time
+}
+
+// gitHubRateLimit is shared by every GitHub source, so an exhausted budget pauses all of them.
+var gitHubRateLimit = &RateLimit{}
+
+// Wait blocks until the budget is reset when it is exhausted, or the context is canceled.
+func (r *RateLimit) Wait(ctx context.Context) error {
+	r.mu.Lock()
+	exhausted := r.known && r.remaining <= 0
+	reset := r.reset
+	r.mu.Unlock()
+
+	if !exhausted {
+		return nil
+	}
+
+	if wait := time.Until(reset); wait > 0 {
+		// A second of margin for clock skew with the server
+		wait += time.Second
+		slog.Warn("GitHub rate limit exhausted, pausing", "reset", reset, "wait", wait.Round(time.Second))
+		return utils.Sleep(ctx, wait)
+	}
+
+	return nil
+}
+
+// Update records the budget reported in the response headers.
+func (r *RateLimit) Update(header http.Header) {
+	limit, err1 := strconv.Atoi(header.Get("X-RateLimit-Limit"))
+	remaining, err2 := strconv.Atoi(header.Get("X-RateLimit-Remaining"))
+	reset, err3 := strconv.ParseInt(header.Get("X-RateLimit-Reset"), 10, 64)
+	if err1 != nil || err2 != nil || err3 != nil {
+		return
+	}
+
+	r.mu.Lock()
+	previous := r.remaining
+	wasKnown := r.known
+	r.known = true
+	r.limit = limit
+	r.remaining = remaining
+	r.reset = time.Unix(reset, 0)
+	r.mu.Unlock()
+
+	metrics.GitHubRateLimitRemaining.Set(float64(remaining))
+	metrics.GitHubRateLimitReset.Set(float64(reset))
+
+	log := slog.With("remaining", remaining, "limit", limit, "reset", time.Unix(reset, 0))
+	if low := limit / 10; remaining < low && (!wasKnown || previous >= low) {
+		log.Warn("GitHub rate limit running low")
+	} else {
+		log.Debug("GitHub rate limit")
+	}
+}
+
+// doGitHubRequest sends a request to the GitHub API within the rate limit budget.
+func doGitHubRequest(req *http.Request) (*http.Response, error) {
+	if err := gitHubRateLimit.Wait(req.Context()); err != nil {
+		return nil, err
+	}
+
+	resp, err := utils.DoRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	gitHubRateLimit.Update(resp.Header)
+	return resp, nil
+}
@@ -0,0 +1,89 @@
+package sources
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"main/src/metrics"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func rateLimitHeader(limit, remaining int, reset time.Time) http.Header {
+	header := http.Header{}
+	header.Set("X-RateLimit-Limit", strconv.Itoa(limit))
+	header.Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+	header.Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+	return header
+}
+
+func TestRateLimitUpdate(t *testing.T) {
+	reset := time.Unix(1792378800, 0)
+
+	rateLimit := &RateLimit{}
+	rateLimit.Update(http.Header{"X-RateLimit-Limit": {"not a number"}})
+	if rateLimit.known {
+		t.Errorf("budget known from invalid headers")
+	}
+
+	rateLimit.Update(rateLimitHeader(5000, 42, reset))
+	if !rateLimit.known || rateLimit.limit != 5000 || rateLimit.remaining != 42 || !rateLimit.reset.Equal(reset) {
+		t.Errorf("budget = %d/%d until %s, want 42/5000 until %s", rateLimit.remaining, rateLimit.limit, rateLimit.reset, reset)
+	}
+
+	rec := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	for _, want := range []string{
+		"gitbackup_github_rate_limit_remaining 42\n",
+		fmt.Sprintf("gitbackup_github_rate_limit_reset_timestamp_seconds %s\n", strconv.FormatFloat(float64(reset.Unix()), 'g', -1, 64)),
+	} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("metrics do not contain %q:\n%s", want, rec.Body)
+		}
+	}
+}
+
+func TestRateLimitWait(t *testing.T) {
+	tests := []struct {
+		desc      string
+		header    http.Header
+		timeout   time.Duration
+		wantErr   error
+		wantPause time.Duration
+	}{
+		{"unknown budget", nil, time.Second, nil, 0},
+		{"budget left", rateLimitHeader(5000, 1, time.Now().Add(time.Hour)), time.Second, nil, 0},
+		{"exhausted budget already reset", rateLimitHeader(5000, 0, time.Now().Add(-time.Minute)), time.Second, nil, 0},
+		{"exhausted budget", rateLimitHeader(5000, 0, time.Now().Add(time.Hour)), 50 * time.Millisecond, context.DeadlineExceeded, 50 * time.Millisecond},
+		// Reset is in whole seconds, and a second of margin is added
+		{"exhausted budget reset soon", rateLimitHeader(5000, 0, time.Now()), 5 * time.Second, nil, 0},
+		{"exhausted budget reset in a second", rateLimitHeader(5000, 0, time.Now().Add(time.Second)), 5 * time.Second, nil, time.Second},
+	}
+
+	for _, tt := range tests {
+		rateLimit := &RateLimit{}
+		if tt.header != nil {
+			rateLimit.Update(tt.header)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), tt.timeout)
+		start := time.Now()
+		err := rateLimit.Wait(ctx)
+		elapsed := time.Since(start)
+		cancel()
+
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: error = %v, want %v", tt.desc, err, tt.wantErr)
+		}
+		if elapsed < tt.wantPause {
+			t.Errorf("%s: paused %s, want at least %s", tt.desc, elapsed, tt.wantPause)
+		}
+		if tt.wantPause == 0 && elapsed > 100*time.Millisecond {
+			t.Errorf("%s: paused %s, want no pause", tt.desc, elapsed)
+		}
+	}
+}
@@ -1,29 +1,51 @@
 package sources
 
+import "context"
+
 const (
 	GitHubID      = "github"
 	HuggingFaceID = "huggingface"
 )
 
+// Types of the Hugging Face repositories
+const (
+	TypeModel   = "model"
+	TypeDataset = "dataset"
+)
+
 type Source interface {
-	Paginate(username string, prev *PaginationResponse) (*PaginationResponse, error)
+	ID() string
+	Paginate(ctx context.Context, username string, prev *PaginationResponse) (*PaginationResponse, error)
 	GetWikiURL(username, repoName string) string
-	FetchReleases(username, repoName string) ([]SourceRelease, error)
+	FetchReleases(ctx context.Context, username, repoName string) ([]SourceRelease, error)
+}
+
+// Resolver is implemented by the sources that can follow a renamed or transferred repository to its current URL.
+type Resolver interface {
+	// ResolveURL returns the current clone URL of the repository, or an empty string when it no longer exists
+	ResolveURL(ctx context.Context, cloneURL string) (string, error)
 }
 
 type PaginationResponse struct {
-	Repositories []SourceRepository
+	Repositories []SourceRepository `json:"-"`
 
-	NextPage   int
-	NextCursor *string
+	NextPage   int     `json:"next_page"`
+	NextCursor *string `json:"next_cursor"`
 
-	Metadata any
+	Metadata map[string]string `json:"metadata"`
 }
 
+// SourceRepository is a repository of a source. Metadata left nil or empty is not known to the source.
 type SourceRepository struct {
-	Name        string
-	URL         string
-	Description *string
+	Name          string
+	URL           string
+	Type          string
+	Description   *string
+	Homepage      *string
+	Topics        []string
+	DefaultBranch string
+	Archived      *bool
+	AvatarURL     string
 }
 
 type SourceRelease struct {
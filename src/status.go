@@ -0,0 +1,383 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"main/src/metrics"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	RepoStatusRunning = "running"
+	RepoStatusSynced  = "synced"
+	RepoStatusFailed  = "failed"
+	RepoStatusSkipped = "skipped"
+	RepoStatusDeleted = "deleted"
+
+	// maxRuns is the number of finished runs kept in the history
+	maxRuns = 20
+)
+
+type RunStatus struct {
+	ID         int        `json:"id"`
+	Trigger    string     `json:"trigger"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+	Synced     int        `json:"synced"`
+	Failed     int        `json:"failed"`
+	Skipped    int        `json:"skipped"`
+}
+
+type RepoStatus struct {
+	Source   string `json:"source"`
+	Username string `json:"username"`
+	Name     string `json:"name"`
+
+	Status      string     `json:"status"`
+	Reason      string     `json:"reason,omitempty"`
+	Error       string     `json:"error,omitempty"`
+	StartedAt   time.Time  `json:"started_at"`
+	Duration    float64    `json:"duration_seconds"`
+	LastSuccess *time.Time `json:"last_success"`
+}
+
+func (r *RepoStatus) Key() string {
+	return strings.ToLower(fmt.Sprintf("%s/%s/%s", r.Source, r.Username, r.Name))
+}
+
+// IsStale reports whether the repository has not been synced successfully within the given duration.
+func (r *RepoStatus) IsStale(after time.Duration, now time.Time) bool {
+	if r.Status == RepoStatusSkipped || r.Status == RepoStatusDeleted {
+		return false
+	}
+
+	return r.LastSuccess == nil || now.Sub(*r.LastSuccess) > after
+}
+
+// StatusStore keeps track of the runs and the outcome of every repository, so
+// they can be inspected through the HTTP API.
+type StatusStore struct {
+	mu   sync.Mutex
+	path string
+
+	Current *RunStatus             `json:"current"`
+	Runs    []*RunStatus           `json:"runs"`
+	Repos   map[string]*RepoStatus `json:"repositories"`
+	NextID  int                    `json:"next_id"`
+
+	// results holds the outcome of every repository of the current run
+	results []RepoResult
+
+	// groupFailures holds the groups of the current run that failed as a whole
+	groupFailures []GroupFailure
+}
+
+// Status is the status store fed by every sync of the process, persisted once loaded.
+var Status = NewStatusStore("")
+
+func NewStatusStore(path string) *StatusStore {
+	return &StatusStore{
+		path:   path,
+		Runs:   make([]*RunStatus, 0),
+		Repos:  make(map[string]*RepoStatus),
+		NextID: 1,
+	}
+}
+
+// Load restores the status persisted at path by previous runs, and persists the next changes there.
+// With an empty path, nothing is persisted.
+func (s *StatusStore) Load(path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.path = path
+	return s.load()
+}
+
+// load reads the persisted status, the caller must hold the lock.
+func (s *StatusStore) load() error {
+	if len(s.path) == 0 {
+		return nil
+	}
+
+	content, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(content, s); err != nil {
+		return err
+	}
+
+	for _, repo := range s.Repos {
+		if repo.LastSuccess != nil {
+			metrics.LastSuccess.Set(float64(repo.LastSuccess.Unix()), repo.Source, repo.Username, repo.Name)
+		}
+	}
+
+	// A run that was in progress when the process stopped will never finish
+	if s.Current != nil {
+		s.Runs = append(s.Runs, s.Current)
+		s.Current = nil
+	}
+
+	return nil
+}
+
+// save persists the status, the caller must hold the lock.
+func (s *StatusStore) save() {
+	if len(s.path) == 0 {
+		return
+	}
+
+	content, err := json.Marshal(s)
+	if err != nil {
+		slog.Error("Saving status", "error", err)
+		return
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		slog.Error("Saving status", "error", err)
+		return
+	}
+
+	// Write to a temporary file first so the API and other processes never read a half-written status
+	tmpPath := s.path + ".tmp"
+	if err := os.WriteFile(tmpPath, content, 0644); err != nil {
+		slog.Error("Saving status", "error", err)
+		return
+	}
+
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		slog.Error("Saving status", "error", err)
+	}
+}
+
+func (s *StatusStore) StartRun(trigger string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Picks up the runs persisted by the sync command since the daemon loaded the status
+	if s.Current == nil {
+		if err := s.load(); err != nil {
+			slog.Error("Loading status", "error", err)
+		}
+	}
+
+	s.Current = &RunStatus{
+		ID:        s.NextID,
+		Trigger:   trigger,
+		StartedAt: time.Now(),
+	}
+	s.NextID++
+	s.results = make([]RepoResult, 0)
+	s.groupFailures = make([]GroupFailure, 0)
+
+	s.save()
+}
+
+// FinishRun ends the current run and returns its report.
+func (s *StatusStore) FinishRun() *RunReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.Current == nil {
+		return nil
+	}
+
+	now := time.Now()
+	s.Current.FinishedAt = &now
+
+	s.Runs = append(s.Runs, s.Current)
+	if len(s.Runs) > maxRuns {
+		s.Runs = s.Runs[len(s.Runs)-maxRuns:]
+	}
+	report := &RunReport{
+		Run:           *s.Current,
+		Repositories:  s.results,
+		GroupFailures: s.groupFailures,
+	}
+
+	s.Current = nil
+	s.results = nil
+	s.groupFailures = nil
+
+	s.save()
+	return report
+}
+
+func (s *StatusStore) StartRepo(source, username, name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	repo := s.repo(source, username, name)
+	repo.Status = RepoStatusRunning
+	repo.Reason = ""
+	repo.Error = ""
+	repo.StartedAt = time.Now()
+	repo.Duration = 0
+}
+
+// SkipRepo records a repository that was skipped and why.
+func (s *StatusStore) SkipRepo(source, username, name, reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	metrics.Repositories.Inc(source, username, RepoStatusSkipped)
+
+	repo := s.repo(source, username, name)
+	repo.Status = RepoStatusSkipped
+	repo.Reason = reason
+	repo.Error = ""
+
+	if s.Current != nil {
+		s.Current.Skipped++
+	}
+
+	s.results = append(s.results, RepoResult{
+		Source:   source,
+		Username: username,
+		Name:     name,
+		Outcome:  OutcomeSkipped,
+		Reason:   reason,
+	})
+}
+
+// DeletedRepo records a repository deleted upstream and the action applied to its project.
+// It is only added to the run report the first time it is detected, which is reported by the return value.
+func (s *StatusStore) DeletedRepo(source, username, name, action string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	repo := s.repo(source, username, name)
+	if repo.Status == RepoStatusDeleted {
+		return false
+	}
+
+	metrics.Repositories.Inc(source, username, RepoStatusDeleted)
+
+	repo.Status = RepoStatusDeleted
+	repo.Reason = action
+	repo.Error = ""
+
+	s.results = append(s.results, RepoResult{
+		Source:   source,
+		Username: username,
+		Name:     name,
+		Outcome:  OutcomeDeleted,
+		Reason:   action,
+	})
+
+	s.save()
+	return true
+}
+
+// FailGroup records an error that stopped a whole group, or the whole run when source is empty.
+func (s *StatusStore) FailGroup(source, username string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.Current == nil {
+		return
+	}
+
+	s.Current.Failed++
+	s.groupFailures = append(s.groupFailures, GroupFailure{
+		Source:   source,
+		Username: username,
+		Error:    err.Error(),
+	})
+
+	s.save()
+}
+
+// FinishRepo records the outcome of a repository started with StartRepo.
+func (s *StatusStore) FinishRepo(result RepoResult, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	source, username, name := result.Source, result.Username, result.Name
+
+	repo := s.repo(source, username, name)
+	repo.Duration = now.Sub(repo.StartedAt).Seconds()
+	result.Duration = repo.Duration
+
+	if err != nil {
+		repo.Status = RepoStatusFailed
+		repo.Error = err.Error()
+		if s.Current != nil {
+			s.Current.Failed++
+		}
+
+		result.Outcome = OutcomeFailed
+		result.Error = err.Error()
+	} else {
+		repo.Status = RepoStatusSynced
+		repo.LastSuccess = &now
+		if s.Current != nil {
+			s.Current.Synced++
+		}
+
+		metrics.LastSuccess.Set(float64(now.Unix()), source, username, name)
+
+		if len(result.Outcome) == 0 {
+			result.Outcome = OutcomeUpdated
+		}
+	}
+	metrics.Repositories.Inc(source, username, repo.Status)
+
+	if s.results != nil {
+		s.results = append(s.results, result)
+	}
+
+	s.save()
+}
+
+// repo returns the status of a repository, creating it when missing. The caller must hold the lock.
+func (s *StatusStore) repo(source, username, name string) *RepoStatus {
+	repo := &RepoStatus{Source: source, Username: username, Name: name}
+	if existing, ok := s.Repos[repo.Key()]; ok {
+		return existing
+	}
+
+	s.Repos[repo.Key()] = repo
+	return repo
+}
+
+// Snapshot returns a copy of the current run, the finished runs (newest first) and the repositories sorted by key.
+func (s *StatusStore) Snapshot() (*RunStatus, []RunStatus, []RepoStatus) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var current *RunStatus
+	if s.Current != nil {
+		c := *s.Current
+		current = &c
+	}
+
+	runs := make([]RunStatus, 0, len(s.Runs))
+	for i := len(s.Runs) - 1; i >= 0; i-- {
+		runs = append(runs, *s.Runs[i])
+	}
+
+	repos := make([]RepoStatus, 0, len(s.Repos))
+	for _, repo := range s.Repos {
+		repos = append(repos, *repo)
+	}
+	sort.Slice(repos, func(i, j int) bool {
+		return repos[i].Key() < repos[j].Key()
+	})
+
+	return current, runs, repos
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStatusStoreRuns(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "status.json")
+
+	// The daemon loads the status once, then a sync command runs next to it
+	daemon := NewStatusStore("")
+	if err := daemon.Load(path); err != nil {
+		t.Fatal(err)
+	}
+
+	sync := NewStatusStore("")
+	if err := sync.Load(path); err != nil {
+		t.Fatal(err)
+	}
+	sync.StartRun("sync")
+	sync.FinishRun()
+
+	if _, err := os.Stat(path + ".tmp"); err == nil {
+		t.Errorf("temporary status file left behind")
+	}
+
+	daemon.StartRun("schedule")
+	daemon.FinishRun()
+
+	reloaded := NewStatusStore("")
+	if err := reloaded.Load(path); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(reloaded.Runs) != 2 {
+		t.Fatalf("got %d runs, want the sync and the scheduled runs", len(reloaded.Runs))
+	}
+	for i, want := range []struct {
+		id      int
+		trigger string
+	}{{1, "sync"}, {2, "schedule"}} {
+		if run := reloaded.Runs[i]; run.ID != want.id || run.Trigger != want.trigger {
+			t.Errorf("run %d = %d %s, want %d %s", i, run.ID, run.Trigger, want.id, want.trigger)
+		}
+	}
+}
+
+func TestStatusStoreInMemory(t *testing.T) {
+	status := NewStatusStore("")
+	if err := status.Load(""); err != nil {
+		t.Fatal(err)
+	}
+
+	status.StartRun("sync")
+	if report := status.FinishRun(); report == nil || len(status.Runs) != 1 {
+		t.Errorf("run not recorded in memory")
+	}
+}
@@ -1,246 +1,559 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"main/src/sources"
 	"main/src/utils"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
+	"time"
 )
 
-func SyncUser(gitlab *GitLab, dufs *Dufs, sourceCfg ConfigRepo, groupCfg ConfigGroup, source sources.Source) {
-	count := 1
+const (
+	// LockPath is the lock file preventing overlapping runs from sharing the working directory.
+	LockPath = "/tmp/git-backup/git-backup.lock"
 
-	result, err := source.Paginate(groupCfg.Username, nil)
-	for {
-		if err != nil {
-			fmt.Println(err)
+	// lockRetryDelay is how often the daemon checks whether the run in progress released the lock
+	lockRetryDelay = 30 * time.Second
+)
+
+// SyncGroups syncs the groups that pass the filters, and the include function
+// when given, while holding the run lock. When the context is canceled the
+// current repository is aborted, the checkpoint is kept for --resume and the
+// report of what was done is still written.
+func SyncGroups(ctx context.Context, config *Configuration, opts *Options, checkpoint *Checkpoint, trigger string, include func(i int, group ConfigGroup) bool) (*RunReport, error) {
+	unlock, err := utils.Lock(LockPath)
+	if err != nil {
+		return failRun(config, trigger, err), err
+	}
+	defer unlock()
+
+	return syncGroups(ctx, config, opts, checkpoint, trigger, include)
+}
+
+// failRun reports a run that could not start as failed as a whole.
+func failRun(config *Configuration, trigger string, err error) *RunReport {
+	Status.StartRun(trigger)
+	Status.FailGroup("", "", err)
+
+	report := Status.FinishRun()
+	WriteReports(config, report)
+	Notify(config, report)
+	return report
+}
+
+// syncGroups is SyncGroups, once the run lock is held.
+func syncGroups(ctx context.Context, config *Configuration, opts *Options, checkpoint *Checkpoint, trigger string, include func(i int, group ConfigGroup) bool) (*RunReport, error) {
+	Status.StartRun(trigger)
+
+	// A filtered run does not go through every repository, so it must not move or clear the checkpoint of a full run
+	filtered := opts.Filtered() || include != nil
+	if filtered {
+		checkpoint = NewCheckpoint("")
+	}
+
+	gitlab, dufs := newDestinations(config)
+	srcs := newSources(config)
+
+	for i, configRepo := range config.Groups {
+		if ctx.Err() != nil {
 			break
 		}
 
-		if len(result.Repositories) == 0 {
-			break
+		if !opts.MatchGroup(configRepo) || (include != nil && !include(i, configRepo)) {
+			continue
 		}
 
-		for _, remote := range result.Repositories {
-			if gitlab.IsReservedName(remote.Name) {
-				fmt.Printf("Skipping repository %s: reserved name\n", remote.Name)
-				continue
-			}
+		if i < checkpoint.Group {
+			slog.Info("Skipping group", "source", configRepo.Source, "group", configRepo.Username, "reason", "from --resume")
+			continue
+		}
 
-			if !gitlab.IsValidName(remote.Name) {
-				fmt.Printf("Skipping repository %s: invalid name\n", remote.Name)
-				continue
+		source, configSource := srcs[configRepo.Source], config.GetSourceConfig(configRepo.Source)
+		if source == nil {
+			err := fmt.Errorf("source %s is not configured", configRepo.Source)
+			slog.Error("Resolving source", "source", configRepo.Source, "group", configRepo.Username, "error", err)
+			Status.FailGroup(configRepo.Source, configRepo.Username, err)
+			continue
+		}
+
+		slog.Info("Evaluating group", "source", configRepo.Source, "group", configRepo.Username)
+
+		configRepo, err := resolveGroup(ctx, gitlab, configRepo, true)
+		if err != nil {
+			slog.Error("Resolving GitLab group", "source", configRepo.Source, "group", configRepo.Username, "error", err)
+			Status.FailGroup(configRepo.Source, configRepo.Username, fmt.Errorf("resolving GitLab group: %w", err))
+			continue
+		}
+
+		SyncUser(ctx, gitlab, dufs, configSource, configRepo, i, source, checkpoint, opts)
+	}
+
+	if ctx.Err() != nil {
+		if !filtered {
+			slog.Warn("Run interrupted, the checkpoint is kept for --resume")
+		}
+	} else if err := checkpoint.Remove(); err != nil {
+		// The run finished, the next one should start from the beginning
+		slog.Error("Removing checkpoint", "error", err)
+	}
+
+	report := Status.FinishRun()
+	WriteReports(config, report)
+	Notify(config, report)
+
+	return report, ctx.Err()
+}
+
+// listRepositories lists every repository of the user on the source. On error, the repositories
+// listed so far are returned with it.
+func listRepositories(ctx context.Context, source sources.Source, username string) ([]sources.SourceRepository, error) {
+	remotes := make([]sources.SourceRepository, 0)
+
+	result, err := source.Paginate(ctx, username, nil)
+	for ; err == nil && len(result.Repositories) > 0; result, err = source.Paginate(ctx, username, result) {
+		remotes = append(remotes, result.Repositories...)
+	}
+
+	return remotes, err
+}
+
+func SyncUser(ctx context.Context, gitlab *GitLab, dufs *Dufs, sourceCfg ConfigRepo, groupCfg ConfigGroup, groupIndex int, source sources.Source, checkpoint *Checkpoint, opts *Options) {
+	log := slog.With("source", groupCfg.Source, "group", groupCfg.Username)
+
+	resumeCursor, resumeAfter, count := checkpoint.Resume(groupIndex, groupCfg)
+	if len(resumeAfter) > 0 {
+		log.Info("Resuming after repository", "repo", resumeAfter)
+	}
+
+	// All pages are listed first, even when resuming, as naming the projects and reconciling the GitLab group
+	// need the complete list of repositories
+	type page struct {
+		cursor *sources.PaginationResponse
+		result *sources.PaginationResponse
+	}
+	pages := make([]page, 0)
+
+	var cursor *sources.PaginationResponse
+	result, err := source.Paginate(ctx, groupCfg.Username, cursor)
+	for ; err == nil && len(result.Repositories) > 0; result, err = source.Paginate(ctx, groupCfg.Username, result) {
+		pages = append(pages, page{cursor: cursor, result: result})
+		cursor = result
+	}
+
+	if err != nil && ctx.Err() == nil {
+		log.Error("Paginating repositories", "error", err)
+		Status.FailGroup(groupCfg.Source, groupCfg.Username, fmt.Errorf("paginating repositories: %w", err))
+	}
+
+	upstream := make([]sources.SourceRepository, 0)
+	for _, p := range pages {
+		upstream = append(upstream, p.result.Repositories...)
+	}
+
+	// The existing projects keep their names when names collide
+	projects, listErr := listGroupProjects(ctx, gitlab, groupCfg)
+	if listErr != nil {
+		log.Error("Listing GitLab projects", "error", listErr)
+	}
+	names := projectNames(gitlab, groupCfg, upstream, projects)
+
+	// A filtered run does not see every repository, and neither does a failed listing
+	if err == nil && listErr == nil && len(opts.Repo) == 0 {
+		// Renamed projects must be found by the sync instead of being imported again
+		reconcileGroup(ctx, gitlab, groupCfg, source, projects, upstream, names)
+	}
+
+	// A resumed run skips the pages before the checkpoint page
+	first := 0
+	if len(resumeAfter) > 0 {
+		first = slices.IndexFunc(pages, func(p page) bool { return sameCursor(p.cursor, resumeCursor) })
+		if first == -1 {
+			log.Warn("Checkpoint page not found, resuming from the start of the group", "repo", resumeAfter)
+			first, resumeAfter, count = 0, "", 1
+		}
+	}
+
+	for _, p := range pages[first:] {
+		repositories := p.result.Repositories
+		if len(resumeAfter) > 0 {
+			idx := indexOfRepository(repositories, resumeAfter)
+			if idx == -1 {
+				log.Warn("Repository not found in checkpoint page, resuming from the start of the page", "repo", resumeAfter)
 			}
 
-			if groupCfg.Skip != nil && *groupCfg.Skip >= count {
-				fmt.Printf("Skipping repository %s: from --skip\n", remote.Name)
-				count++
+			repositories = repositories[idx+1:]
+			resumeAfter = ""
+		}
+
+		for _, remote := range repositories {
+			// Filtered out repositories still take their position, so skip applies as in a full run
+			if !opts.MatchRepository(remote.Name) {
+				_, _, count = resolveRemote(sourceCfg, groupCfg, remote, count)
 				continue
 			}
 
-			// Find configuration for that repo
-			cfg := sourceCfg // Default to source's config
-			if len(groupCfg.Repositories) > 0 {
-				cf := groupCfg.GetConfig(remote.Name)
-				if cf != nil {
-					cfg = cf.ConfigRepo
+			count = syncRemote(ctx, gitlab, dufs, sourceCfg, groupCfg, source, remote, names[remote.URL], count, opts)
 
-					if *cf.Exclude {
-						fmt.Printf("Skipping repository %s: from --exclude\n", remote.Name)
-						continue
-					}
-				} else {
-					fmt.Printf("Skipping repository %s: from --include-only\n", remote.Name)
-					continue
-				}
+			// An interrupted repository is not completed, a resumed run starts from it
+			if ctx.Err() != nil {
+				return
 			}
 
-			fmt.Printf("\n%d. Evaluating repository %s\n", count, remote.Name)
-			prj := NewProject(gitlab, dufs, *groupCfg.GitLabGroupID, source, groupCfg.Username, remote, cfg)
-			if err := SyncRepo(prj); err != nil {
-				fmt.Println(err)
+			if err := checkpoint.Completed(groupIndex, groupCfg, p.cursor, remote.Name, count); err != nil {
+				log.Error("Saving checkpoint", "error", err)
 			}
+		}
+	}
 
-			// Close project and delete any allocated storage
-			prj.Prune()
+	if err != nil {
+		return
+	}
+
+	if err := checkpoint.CompletedGroup(groupIndex); err != nil {
+		log.Error("Saving checkpoint", "error", err)
+	}
+}
+
+// syncRemote evaluates a single source repository, named name in GitLab, and returns the updated repository counter.
+func syncRemote(ctx context.Context, gitlab *GitLab, dufs *Dufs, sourceCfg ConfigRepo, groupCfg ConfigGroup, source sources.Source, remote sources.SourceRepository, name string, count int, opts *Options) int {
+	log := slog.With("source", groupCfg.Source, "group", groupCfg.Username, "repo", remote.Name)
+
+	cfg, reason, next := resolveRemote(sourceCfg, groupCfg, remote, count)
+	if len(reason) > 0 {
+		log.Info("Skipping repository", "reason", reason)
+		Status.SkipRepo(groupCfg.Source, groupCfg.Username, remote.Name, reason)
+		return next
+	}
+
+	if name != remote.Name {
+		log = log.With("gitlab_name", name)
+	}
+	log.Info("Evaluating repository", "count", count)
+	Status.StartRepo(groupCfg.Source, groupCfg.Username, remote.Name)
+
+	repoGroup, err := repositoryGroup(ctx, gitlab, groupCfg, remote, true)
 
-			count++
+	prj := NewProject(gitlab, dufs, *repoGroup.GitLabGroupID, name, source, groupCfg.Username, remote, cfg)
+	prj.Only = opts.Only
+	if err == nil {
+		err = SyncRepo(ctx, prj)
+	}
+	if err != nil && ctx.Err() != nil {
+		err = fmt.Errorf("interrupted: %w", err)
+		log.Warn("Aborted repository", "error", err)
+	} else if err != nil {
+		log.Error("Syncing repository", "error", err)
+	}
+	Status.FinishRepo(prj.Result, err)
+
+	// Close project and delete any allocated storage
+	prj.Prune()
+
+	return next
+}
+
+// resolveRemote finds the configuration a source repository should be synced with.
+// A non-empty reason means the repository must be skipped. The returned counter
+// is the one the next repository should use.
+func resolveRemote(sourceCfg ConfigRepo, groupCfg ConfigGroup, remote sources.SourceRepository, count int) (ConfigRepo, string, int) {
+	if groupCfg.Skip != nil && *groupCfg.Skip >= count {
+		return sourceCfg, "from --skip", count + 1
+	}
+
+	// Find configuration for that repo
+	cfg := sourceCfg // Default to source's config
+	if len(groupCfg.Repositories) > 0 {
+		cf := groupCfg.GetConfig(remote.Name)
+		if cf == nil {
+			return cfg, "from --include-only", count
 		}
 
-		result, err = source.Paginate(groupCfg.Username, result)
+		cfg = cf.ConfigRepo
+		if *cf.Exclude {
+			return cfg, "from --exclude", count
+		}
 	}
+
+	return cfg, "", count + 1
 }
 
-func SyncRepo(prj *Project) error {
-	repoID, err := prj.RetrieveExistingRepo()
+// sameCursor reports whether two pagination cursors request the same page. Nil is the first page.
+func sameCursor(a, b *sources.PaginationResponse) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	if (a.NextCursor == nil) != (b.NextCursor == nil) || (a.NextCursor != nil && *a.NextCursor != *b.NextCursor) {
+		return false
+	}
+
+	return a.NextPage == b.NextPage && maps.Equal(a.Metadata, b.Metadata)
+}
+
+func indexOfRepository(repositories []sources.SourceRepository, name string) int {
+	for i, repo := range repositories {
+		if repo.Name == name {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// importFallback reports whether the repository is cloned and pushed after the GitLab import ended with err.
+func importFallback(prj *Project, err error) bool {
+	var importErr *ImportError
+	return errors.As(err, &importErr) && prj.Destination.ImportFallback && prj.ShouldSync(OnlyGit)
+}
+
+// pushFromSource clones the source repository and pushes its branches and tags to GitLab.
+func pushFromSource(ctx context.Context, prj *Project, log *slog.Logger) error {
+	log.Info("Cloning repository from source")
+	if err := prj.CloneFromSource(ctx); err != nil {
+		return err
+	}
+
+	return pushToGitLab(ctx, prj, log)
+}
+
+// pushToGitLab pushes the branches and tags of the cloned repository to GitLab, following the rewrite policy.
+func pushToGitLab(ctx context.Context, prj *Project, log *slog.Logger) error {
+	log.Debug("Adding GitLab as a remote repository")
+	if err := prj.AddRemoteToRepo(); err != nil {
+		return err
+	}
+
+	branches, err := prj.GetBranches()
 	if err != nil {
 		return err
 	}
 
-	// Sync repository
-	if repoID == -1 {
-		fmt.Println("- Importing new repository in GitLab...")
-		repoID, err = prj.Import()
-		if err != nil {
+	skip, err := guardRewrites(ctx, prj, log, branches)
+	if err != nil {
+		return err
+	}
+
+	log.Info("Pushing branches to GitLab", "count", len(branches))
+	for _, branch := range branches {
+		if skip[branchRef(branch)] {
+			continue
+		}
+
+		log.Debug("Pushing branch", "branch", branch)
+		if err := prj.PushBranch(ctx, branch); err != nil {
 			return err
 		}
-		fmt.Println("- Importing new repository in GitLab with project ID:", repoID)
+	}
 
-		fmt.Println("- Create 'original_url' attribute with value:" + prj.SourceRepository.URL)
-		err = prj.SetOriginalURL()
-		if err != nil {
+	log.Info("Pushing tags to GitLab")
+	if err := prj.PushAllTags(ctx, skip); err != nil {
+		return err
+	}
+
+	if err := syncDeletions(ctx, prj, log); err != nil {
+		return err
+	}
+
+	if len(skip) > 0 {
+		refused := make([]string, 0, len(skip))
+		for ref := range skip {
+			refused = append(refused, ref.String())
+		}
+		sort.Strings(refused)
+
+		return fmt.Errorf("refused to overwrite rewritten refs: %s", strings.Join(refused, ", "))
+	}
+
+	return nil
+}
+
+func SyncRepo(ctx context.Context, prj *Project) error {
+	log := prj.Log
+
+	repoID, err := prj.RetrieveExistingRepo(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Archived projects are read-only, they are only archived again once synced
+	defer archiveProject(ctx, prj, log)
+
+	if repoID != -1 {
+		if err := applyProjectSettings(ctx, prj, log.With("project_id", repoID)); err != nil {
 			return err
 		}
+	}
 
-		fmt.Println("- Waiting for repository import to finish...")
-		err = prj.LockUntilImport()
+	// Sync repository
+	if repoID == -1 && !prj.ShouldSync(OnlyGit) {
+		return fmt.Errorf("repository does not exist in GitLab, sync its git data first")
+	} else if repoID == -1 {
+		log.Info("Importing new repository in GitLab")
+		repoID, err = prj.Import(ctx)
 		if err != nil {
 			return err
 		}
+		log = log.With("project_id", repoID)
+		log.Info("Imported new repository in GitLab")
+		prj.Result.Outcome = OutcomeCreated
 
-		protectedBranches, err := prj.GetProtectedBranches()
-		fmt.Printf("- Found %d protected branches\n", len(protectedBranches))
+		log.Debug("Creating 'original_url' attribute", "url", prj.SourceRepository.URL)
+		err = prj.SetOriginalURL(ctx)
 		if err != nil {
 			return err
 		}
 
-		fmt.Println("  - Unprotecting branches...")
-		for _, branch := range protectedBranches {
-			fmt.Printf("    - Unprotecting %s...\n", branch)
-			err = prj.UnprotectBranch(branch)
+		if prj.DestinationRepository.Name != prj.SourceRepository.Name {
+			log.Debug("Creating 'original_name' attribute", "name", prj.SourceRepository.Name)
+			err = prj.Destination.CreateProjectVariable(ctx, repoID, "original_name", prj.SourceRepository.Name)
 			if err != nil {
 				return err
 			}
 		}
-	} else {
-		fmt.Println("- Repository already exists in GitLab with project ID:", repoID)
-		fmt.Println("- Cloning repository from GitLab...")
-		if err := prj.CloneFromSource(); err != nil {
+
+		log.Info("Waiting for repository import to finish")
+		err = prj.LockUntilImport(ctx)
+
+		fallback := importFallback(prj, err)
+		if fallback {
+			log.Warn("GitLab import did not succeed, falling back to clone and push", "error", err)
+		} else if err != nil {
 			return err
 		}
 
-		fmt.Println("- Adding GitLab as a remote repository..")
-		if err := prj.AddRemoteToRepo(); err != nil {
+		// Before any push, which could start pipelines
+		if err := applyProjectSettings(ctx, prj, log); err != nil {
 			return err
 		}
 
-		fmt.Println("- Pushing branches to GitLab...")
-		branches, err := prj.GetBranches()
+		// GitLab protects the default branch of imported projects
+		err = withUnprotectedBranches(ctx, prj, log, func() error {
+			if fallback {
+				return pushFromSource(ctx, prj, log)
+			}
+			return nil
+		})
 		if err != nil {
 			return err
 		}
+	} else if prj.ShouldSync(OnlyGit) {
+		log = log.With("project_id", repoID)
+		log.Info("Repository already exists in GitLab")
 
-		fmt.Printf("  - Found %d branches\n", len(branches))
-		for _, branch := range branches {
-			fmt.Printf("  - Pushing %s...\n", branch)
-			if err := prj.PushBranch(branch); err != nil {
-				return err
+		err = withUnprotectedBranches(ctx, prj, log, func() error {
+			if *prj.Config.MirrorStrategy == MirrorGitLabPull {
+				return pullMirror(ctx, prj, log)
 			}
+			return pushFromSource(ctx, prj, log)
+		})
+		if err != nil {
+			return err
 		}
+	}
 
-		fmt.Println("- Pushing tags to GitLab...")
-		if err := prj.PushAllTags(); err != nil {
-			return err
+	prj.Result.ProjectID = &repoID
+
+	if err := syncMetadata(ctx, prj, log); err != nil {
+		return err
+	}
+
+	// The avatar is cosmetic, it does not fail the repository
+	if !*prj.Config.Avatar.Exclude {
+		if err := syncAvatar(ctx, prj, log); err != nil {
+			log.Warn("Syncing avatar", "error", err)
 		}
 	}
 
 	// Sync WiKi
-	if !*prj.Config.Wiki.Exclude {
-		fmt.Println("- Checking for source Wiki...")
+	if !*prj.Config.Wiki.Exclude && prj.ShouldSync(OnlyWiki) {
+		log.Info("Checking for source wiki")
 		wikiPrj := prj.GetWikiProject()
+
+		// Close project and delete any allocated storage, even when the sync is aborted
+		defer wikiPrj.Prune()
+
 		if len(wikiPrj.SourceRepository.URL) == 0 {
-			fmt.Println("  - WiKi is not supported...")
+			log.Info("Wiki is not supported")
 		} else {
-			if err := wikiPrj.CloneFromSource(); err == nil {
-				fmt.Println("  - Found remote Wiki, syncing...")
-				if err := wikiPrj.AddRemoteToRepo(); err != nil {
-					return err
-				}
-
-				fmt.Println("  - Pushing branches to GitLab...")
-				branches, err := wikiPrj.GetBranches()
+			if err := wikiPrj.CloneFromSource(ctx); err == nil {
+				log.Info("Found source wiki, syncing")
+				err := pushToGitLab(ctx, wikiPrj, log.With("wiki", true))
+				prj.Result.RefsPreserved += wikiPrj.Result.RefsPreserved
+				prj.Result.RefsDeleted += wikiPrj.Result.RefsDeleted
 				if err != nil {
 					return err
 				}
-
-				fmt.Printf("    - Found %d branches\n", len(branches))
-				for _, branch := range branches {
-					fmt.Printf("    - Pushing %s...\n", branch)
-					if err := wikiPrj.PushBranch(branch); err != nil {
-						return err
-					}
-				}
-
-				fmt.Println("  - Pushing tags to GitLab...")
-				if err := wikiPrj.PushAllTags(); err != nil {
-					return err
-				}
 			}
-
-			// Close project and delete any allocated storage
-			wikiPrj.Prune()
 		}
 	}
 
 	// Sync Releases
-	if !*prj.Config.Releases.Exclude {
-		fmt.Println("- Fetching source releases...")
-		releases, err := prj.Source.FetchReleases(prj.SourceUsername, prj.SourceRepository.Name)
+	if !*prj.Config.Releases.Exclude && prj.ShouldSync(OnlyReleases) {
+		log.Info("Fetching source releases")
+		releases, err := prj.Source.FetchReleases(ctx, prj.SourceUsername, prj.SourceRepository.Name)
 		if err != nil {
 			return err
 		}
 
 		if releases == nil {
-			fmt.Println("  - Releases are not supported...")
+			log.Info("Releases are not supported")
 		} else {
-			fmt.Printf("  - Found %d releases\n", len(releases))
+			log.Info("Found releases", "count", len(releases))
 			for _, release := range releases {
-				fmt.Printf("  - Evaluating release %s...\n", release.TagName)
-				exists, err := prj.ReleaseExists(release.TagName)
+				log := log.With("release", release.TagName)
+				log.Debug("Evaluating release")
+				exists, err := prj.ReleaseExists(ctx, release.TagName)
 				if err != nil {
 					return err
 				}
 
 				if exists {
-					fmt.Println("    - Release already exists, skipping...")
+					log.Debug("Release already exists, skipping")
 					continue
 				}
 
-				fmt.Println("    - Release does not exist, creating...")
-				if err := prj.CreateRelease(release); err != nil {
+				log.Info("Creating release", "assets", len(release.Assets))
+				if err := prj.CreateRelease(ctx, release); err != nil {
 					return err
 				}
+				prj.Result.ReleasesAdded++
 
-				fmt.Printf("    - Found %d assets\n", len(release.Assets))
 				for _, asset := range release.Assets {
-					fmt.Printf("    - Evaluating asset: %s\n", asset.Name)
+					log := log.With("asset", asset.Name)
+					log.Debug("Evaluating asset")
 
 					// If asset is not downloaded, then set the original asset url
 					assetURL := asset.BrowserDownloadUrl
 
 					if !*prj.Config.Releases.Assets.Exclude {
-						fmt.Println("      - Downloading...")
+						log.Info("Downloading asset")
 						assetPath := filepath.Join(prj.GetDir(), "assets__", asset.Name)
-						if err := utils.DownloadAsset(asset.BrowserDownloadUrl, assetPath); err != nil {
+						if err := utils.DownloadAsset(ctx, asset.BrowserDownloadUrl, assetPath); err != nil {
 							return err
 						}
 
 						assetShouldBeUploaded := true
 
+						size, err := utils.GetFileSize(assetPath)
+						if err != nil {
+							return err
+						}
+						log.Debug("Downloaded asset", "size", utils.ConvertFromBytes(size))
+
 						maxSize := *prj.Config.Releases.Assets.MaxSize
 						if maxSize != "none" {
 							maxSizeBytes := utils.ConvertToBytes(maxSize)
 
-							size, err := utils.GetFileSize(assetPath)
-							if err != nil {
-								return err
-							}
-
-							fmt.Printf("      - Size: %s\n", utils.ConvertFromBytes(size))
 							if size >= maxSizeBytes {
-								fmt.Printf("      - Asset %s exceeds the maximum size of %s\n", asset.Name, maxSize)
+								log.Info("Asset exceeds the maximum size, linking the original", "size", utils.ConvertFromBytes(size), "max_size", maxSize)
 								if err := os.Remove(assetPath); err != nil {
 									return err
 								}
@@ -251,7 +564,7 @@ func SyncRepo(prj *Project) error {
 
 						// Upload asset
 						if assetShouldBeUploaded {
-							fmt.Println("      - Uploading asset to storage...")
+							log.Info("Uploading asset to storage")
 
 							assetURL = fmt.Sprintf("/gitlab/projects/prj_%d/tag_%s/%s",
 								repoID,
@@ -259,11 +572,13 @@ func SyncRepo(prj *Project) error {
 								strings.ReplaceAll(asset.Name, "/", "-"),
 							)
 
-							if err := prj.DestinationStorage.UploadFIle(assetPath, assetURL); err != nil {
+							if err := prj.DestinationStorage.UploadFIle(ctx, assetPath, assetURL); err != nil {
 								return err
 							}
 
 							assetURL = prj.DestinationStorage.URL.JoinPath(assetURL).String()
+							prj.Result.AssetsUploaded++
+							prj.Result.Bytes += size
 
 							// Delete file after upload
 							if err := os.Remove(assetPath); err != nil {
@@ -273,12 +588,11 @@ func SyncRepo(prj *Project) error {
 					}
 
 					// Link asset
-					fmt.Println("      - Linking asset to GitLab...")
-					if err := prj.LinkAsset(release.TagName, asset.Name, assetURL); err != nil {
+					log.Debug("Linking asset to GitLab", "url", assetURL)
+					if err := prj.LinkAsset(ctx, release.TagName, asset.Name, assetURL); err != nil {
 						return err
 					}
-
-					fmt.Println("      - Done")
+					prj.Result.AssetsLinked++
 				}
 
 			}
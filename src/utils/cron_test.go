@@ -0,0 +1,91 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	tests := []struct {
+		expr  string
+		valid bool
+	}{
+		{"* * * * *", true},
+		{"30 3 * * 1-5", true},
+		{"*/15 * * * *", true},
+		{"5/10 * * * *", true},
+		{"0 0,12 1,15 * *", true},
+		{"0 0 * * 7", true},
+		{"@daily", true},
+		{" @hourly ", true},
+		{"* * * *", false},
+		{"* * * * * *", false},
+		{"60 * * * *", false},
+		{"* 24 * * *", false},
+		{"* * 0 * *", false},
+		{"* * * 13 *", false},
+		{"* * * * 8", false},
+		{"5-1 * * * *", false},
+		{"*/0 * * * *", false},
+		{"*/x * * * *", false},
+		{"a * * * *", false},
+		{"@sometimes", false},
+	}
+
+	for _, tt := range tests {
+		_, err := ParseCron(tt.expr)
+		if (err == nil) != tt.valid {
+			t.Errorf("ParseCron(%q) error = %v, want valid %t", tt.expr, err, tt.valid)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	// 2024-01-15 is a Monday
+	base := time.Date(2024, 1, 15, 10, 7, 30, 0, time.UTC)
+
+	tests := []struct {
+		desc string
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		{"every minute", "* * * * *", base, time.Date(2024, 1, 15, 10, 8, 0, 0, time.UTC)},
+		{"minute step", "*/15 * * * *", base, time.Date(2024, 1, 15, 10, 15, 0, 0, time.UTC)},
+		{"minute step with start", "5/20 * * * *", base, time.Date(2024, 1, 15, 10, 25, 0, 0, time.UTC)},
+		{"exact time is not repeated", "8 10 * * *", time.Date(2024, 1, 15, 10, 8, 0, 0, time.UTC), time.Date(2024, 1, 16, 10, 8, 0, 0, time.UTC)},
+		{"hour range", "0 9-17 * * *", base, time.Date(2024, 1, 15, 11, 0, 0, 0, time.UTC)},
+		{"hour list", "30 8,20 * * *", base, time.Date(2024, 1, 15, 20, 30, 0, 0, time.UTC)},
+		{"next day", "0 3 * * *", base, time.Date(2024, 1, 16, 3, 0, 0, 0, time.UTC)},
+		{"weekday range", "0 3 * * 1-5", time.Date(2024, 1, 19, 10, 0, 0, 0, time.UTC), time.Date(2024, 1, 22, 3, 0, 0, 0, time.UTC)},
+		{"sunday as 7", "0 0 * * 7", base, time.Date(2024, 1, 21, 0, 0, 0, 0, time.UTC)},
+		{"sunday as 0", "0 0 * * 0", base, time.Date(2024, 1, 21, 0, 0, 0, 0, time.UTC)},
+		{"day of month", "0 0 20 * *", base, time.Date(2024, 1, 20, 0, 0, 0, 0, time.UTC)},
+		{"day or weekday", "0 0 20 * 3", base, time.Date(2024, 1, 17, 0, 0, 0, 0, time.UTC)},
+		{"day or weekday, day first", "0 0 16 * 5", base, time.Date(2024, 1, 16, 0, 0, 0, 0, time.UTC)},
+		{"day step and weekday match both", "0 0 */2 * 1", base, time.Date(2024, 1, 29, 0, 0, 0, 0, time.UTC)},
+		{"day step and weekday skip even days", "0 0 */2 * 1", time.Date(2024, 1, 29, 1, 0, 0, 0, time.UTC), time.Date(2024, 2, 5, 0, 0, 0, 0, time.UTC)},
+		{"weekday step and day", "0 0 1 * */2", base, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"day step only", "0 0 */10 * *", base, time.Date(2024, 1, 21, 0, 0, 0, 0, time.UTC)},
+		{"month rollover", "0 0 1 * *", base, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"year rollover", "0 0 1 1 *", base, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"month list", "0 0 1 3,6 *", base, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{"end of month", "0 0 31 * *", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC)},
+		{"leap day", "0 0 29 2 *", base, time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"next leap day", "0 0 29 2 *", time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"never", "0 0 31 2 *", base, time.Time{}},
+		{"weekly", "@weekly", base, time.Date(2024, 1, 21, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		cron, err := ParseCron(tt.expr)
+		if err != nil {
+			t.Errorf("%s: ParseCron(%q): %v", tt.desc, tt.expr, err)
+			continue
+		}
+
+		if got := cron.Next(tt.from); !got.Equal(tt.want) {
+			t.Errorf("%s: Next(%s) of %q = %s, want %s", tt.desc, tt.from, tt.expr, got, tt.want)
+		}
+	}
+}
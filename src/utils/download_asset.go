@@ -1,15 +1,22 @@
 package utils
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"main/src/metrics"
 	"net/http"
 	"os"
 	"path/filepath"
 )
 
-func DownloadAsset(srcUrl, dstPath string) error {
-	response, err := http.Get(srcUrl)
+func DownloadAsset(ctx context.Context, srcUrl, dstPath string) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, srcUrl, nil)
+	if err != nil {
+		return err
+	}
+
+	response, err := DoRequest(request)
 	if err != nil {
 		return err
 	}
@@ -32,10 +39,34 @@ func DownloadAsset(srcUrl, dstPath string) error {
 	}
 	defer file.Close()
 
-	_, err = io.Copy(file, response.Body)
+	n, err := io.Copy(file, response.Body)
+	metrics.DownloadedBytes.Add(float64(n))
 	if err != nil {
+		// Do not leave a partial asset behind
+		file.Close()
+		os.Remove(dstPath)
 		return err
 	}
 
 	return nil
 }
+
+// GetRemoteFileSize returns the size of a remote file using a HEAD request, or -1 when the server does not report it.
+func GetRemoteFileSize(ctx context.Context, srcUrl string) (int64, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodHead, srcUrl, nil)
+	if err != nil {
+		return -1, err
+	}
+
+	response, err := DoRequest(request)
+	if err != nil {
+		return -1, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return -1, fmt.Errorf("failed to retrieve asset size. HTTP status code: %d", response.StatusCode)
+	}
+
+	return response.ContentLength, nil
+}
@@ -7,7 +7,12 @@ import (
 	"strings"
 )
 
-func OpenConfigFile() ([]byte, error) {
+// OpenConfigFile reads the configuration from the given path, or from the first of the default filenames found when path is empty.
+func OpenConfigFile(path string) ([]byte, error) {
+	if len(path) > 0 {
+		return os.ReadFile(path)
+	}
+
 	filenames := []string{"config.json5", "configuration.json", "config.json5", "configuration.json5"}
 	for _, filename := range filenames {
 		content, err := os.ReadFile(filename)
@@ -0,0 +1,192 @@
+package utils
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+const (
+	// maxAttempts is the number of times a request is sent before giving up
+	maxAttempts = 5
+
+	// baseDelay and maxDelay bound the exponential backoff between attempts
+	baseDelay = time.Second
+	maxDelay  = 30 * time.Second
+
+	// maxRateLimitWait is the longest wait for a rate limit to reset before giving up
+	maxRateLimitWait = time.Hour
+)
+
+// HTTPClient is shared by every request to the sources and destinations. It has no overall
+// timeout, so large assets can be transferred, but connecting and waiting for the headers are bounded.
+var HTTPClient = &http.Client{
+	Transport: &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConnsPerHost:   10,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 2 * time.Minute,
+		ExpectContinueTimeout: time.Second,
+	},
+}
+
+// DoRequest sends the request with HTTPClient, retrying 429 responses, and transient network errors
+// and 5xx responses of idempotent requests, with jittered exponential backoff. Retry-After and
+// X-RateLimit-Reset are honored when present. As in net/http, a request is idempotent when its method
+// is, or when it has an Idempotency-Key header. Requests with a body are only retried when GetBody
+// is set, which http.NewRequest does for in-memory bodies.
+func DoRequest(req *http.Request) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+
+		resp, err := HTTPClient.Do(req)
+
+		wait, retry := retryDelay(req, resp, err, attempt)
+		if !retry || attempt == maxAttempts || !canRewind(req) || req.Context().Err() != nil {
+			return resp, err
+		}
+
+		log := slog.With("method", req.Method, "url", req.URL.Redacted(), "attempt", attempt, "wait", wait)
+		if err != nil {
+			log.Warn("Retrying request", "error", err)
+		} else {
+			log.Warn("Retrying request", "status", resp.StatusCode)
+
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		if err := Sleep(req.Context(), wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Sleep pauses for the given duration, returning early with the error of the context when it is canceled.
+func Sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func canRewind(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// retryDelay returns how long to wait before retrying, and whether the request should be retried at all.
+func retryDelay(req *http.Request, resp *http.Response, err error, attempt int) (time.Duration, bool) {
+	if err != nil {
+		if !isIdempotent(req) || !isTransient(err) {
+			return 0, false
+		}
+		return backoff(attempt), true
+	}
+
+	// A rate limited request was not processed, so it is safe to send again
+	rateLimited := resp.StatusCode == http.StatusTooManyRequests ||
+		(resp.StatusCode == http.StatusForbidden && resp.Header.Get("X-RateLimit-Remaining") == "0")
+
+	if !rateLimited && (!isIdempotent(req) || resp.StatusCode < 500 || resp.StatusCode == http.StatusNotImplemented) {
+		return 0, false
+	}
+
+	if wait, ok := serverDelay(resp.Header); ok {
+		if wait > maxRateLimitWait {
+			return 0, false
+		}
+		return wait, true
+	}
+
+	return backoff(attempt), true
+}
+
+// isIdempotent reports whether sending the request twice has the same effect as sending it once.
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+
+	_, ok := req.Header["Idempotency-Key"]
+	return ok
+}
+
+// isTransient reports whether the error may go away on its own: timeouts, refused or reset
+// connections and connections closed early. Invalid requests and TLS failures are permanent.
+func isTransient(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var certErr *tls.CertificateVerificationError
+	var recordErr tls.RecordHeaderError
+	if errors.As(err, &certErr) || errors.As(err, &recordErr) {
+		return false
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	return errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EPIPE) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// serverDelay reads the wait requested by the server from Retry-After, or from X-RateLimit-Reset
+// when the rate limit is exhausted.
+func serverDelay(header http.Header) (time.Duration, bool) {
+	if value := header.Get("Retry-After"); len(value) > 0 {
+		if seconds, err := strconv.Atoi(value); err == nil {
+			return time.Duration(seconds) * time.Second, true
+		}
+		if date, err := http.ParseTime(value); err == nil {
+			return max(time.Until(date), 0), true
+		}
+	}
+
+	if header.Get("X-RateLimit-Remaining") == "0" {
+		if reset, err := strconv.ParseInt(header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+			// A second of margin for clock skew with the server
+			return max(time.Until(time.Unix(reset, 0)), 0) + time.Second, true
+		}
+	}
+
+	return 0, false
+}
+
+// backoff returns a random delay up to the exponential backoff of the attempt ("full jitter").
+func backoff(attempt int) time.Duration {
+	delay := baseDelay << (attempt - 1)
+	if delay > maxDelay {
+		delay = maxDelay
+	}
+
+	return time.Duration(rand.Int63n(int64(delay)) + 1)
+}
@@ -0,0 +1,131 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// HTTPCache stores the responses of GET requests on disk, so they can be revalidated with
+// If-None-Match and If-Modified-Since instead of downloaded again.
+type HTTPCache struct {
+	Dir string
+}
+
+// CachedResponse is a response read in full, either from the server or from the cache.
+type CachedResponse struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+}
+
+// NewHTTPCache returns a cache stored in dir, or nil, which disables caching, when dir is empty.
+func NewHTTPCache(dir string) *HTTPCache {
+	if len(dir) == 0 {
+		return nil
+	}
+
+	return &HTTPCache{Dir: dir}
+}
+
+// Do sends the request with do, revalidating the cached response when there is one. A 304
+// returns the cached response as is.
+func (c *HTTPCache) Do(req *http.Request, do func(*http.Request) (*http.Response, error)) (*CachedResponse, error) {
+	var cached *CachedResponse
+	if c != nil && req.Method == http.MethodGet {
+		cached = c.load(req)
+	}
+
+	if cached != nil {
+		if etag := cached.Header.Get("ETag"); len(etag) > 0 {
+			req.Header.Set("If-None-Match", etag)
+		}
+		if modified := cached.Header.Get("Last-Modified"); len(modified) > 0 {
+			req.Header.Set("If-Modified-Since", modified)
+		}
+	}
+
+	resp, err := do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		slog.Debug("Using cached response", "url", req.URL.Redacted())
+		return cached, nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &CachedResponse{
+		Status: resp.StatusCode,
+		Header: resp.Header,
+		Body:   body,
+	}
+
+	if c != nil && req.Method == http.MethodGet && resp.StatusCode == http.StatusOK &&
+		(len(resp.Header.Get("ETag")) > 0 || len(resp.Header.Get("Last-Modified")) > 0) {
+		c.save(req, response)
+	}
+
+	return response, nil
+}
+
+// path returns the cache file of a request, keyed by its URL and credentials, as
+// different tokens can see different data.
+func (c *HTTPCache) path(req *http.Request) string {
+	hash := sha256.Sum256([]byte(req.URL.String() + "\n" + req.Header.Get("Authorization")))
+	return filepath.Join(c.Dir, hex.EncodeToString(hash[:])+".json")
+}
+
+func (c *HTTPCache) load(req *http.Request) *CachedResponse {
+	content, err := os.ReadFile(c.path(req))
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			slog.Warn("Reading HTTP cache", "error", err)
+		}
+		return nil
+	}
+
+	var cached CachedResponse
+	if err := json.Unmarshal(content, &cached); err != nil {
+		slog.Warn("Reading HTTP cache", "error", err)
+		return nil
+	}
+
+	return &cached
+}
+
+func (c *HTTPCache) save(req *http.Request, response *CachedResponse) {
+	content, err := json.Marshal(response)
+	if err != nil {
+		slog.Warn("Writing HTTP cache", "error", err)
+		return
+	}
+
+	if err := os.MkdirAll(c.Dir, 0700); err != nil {
+		slog.Warn("Writing HTTP cache", "error", err)
+		return
+	}
+
+	// Written aside and renamed, so a concurrent reader never sees a partial file
+	path := c.path(req)
+	if err := os.WriteFile(path+".tmp", content, 0600); err != nil {
+		slog.Warn("Writing HTTP cache", "error", err)
+		return
+	}
+
+	if err := os.Rename(path+".tmp", path); err != nil {
+		slog.Warn("Writing HTTP cache", "error", err)
+	}
+}
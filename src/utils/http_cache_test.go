@@ -0,0 +1,99 @@
+package utils
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHTTPCache(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+
+		switch r.URL.Path {
+		case "/etag":
+			w.Header().Set("ETag", `"v1"`)
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		case "/modified":
+			w.Header().Set("Last-Modified", "Mon, 15 Jan 2024 10:00:00 GMT")
+			if r.Header.Get("If-Modified-Since") == "Mon, 15 Jan 2024 10:00:00 GMT" {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		case "/missing":
+			w.Header().Set("ETag", `"v1"`)
+			w.WriteHeader(http.StatusNotFound)
+		}
+
+		fmt.Fprintf(w, "%s %d", r.URL.Path, requests)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		desc   string
+		cache  *HTTPCache
+		path   string
+		status int
+		body   string
+	}{
+		{"etag", NewHTTPCache(t.TempDir()), "/etag", http.StatusOK, "/etag 1"},
+		{"last-modified", NewHTTPCache(t.TempDir()), "/modified", http.StatusOK, "/modified 1"},
+		{"no validator is not cached", NewHTTPCache(t.TempDir()), "/plain", http.StatusOK, "/plain 2"},
+		{"errors are not cached", NewHTTPCache(t.TempDir()), "/missing", http.StatusNotFound, "/missing 2"},
+		{"disabled cache", NewHTTPCache(""), "/etag", http.StatusOK, "/etag 2"},
+	}
+
+	for _, tt := range tests {
+		requests = 0
+		for i := 0; i < 2; i++ {
+			req, _ := http.NewRequest(http.MethodGet, server.URL+tt.path, nil)
+
+			resp, err := tt.cache.Do(req, http.DefaultClient.Do)
+			if err != nil {
+				t.Fatalf("%s: Do: %v", tt.desc, err)
+			}
+
+			// The second response comes from the cache when the server answers 304
+			if i == 1 && (resp.Status != tt.status || string(resp.Body) != tt.body) {
+				t.Errorf("%s: response = %d %q, want %d %q", tt.desc, resp.Status, resp.Body, tt.status, tt.body)
+			}
+		}
+
+		if requests != 2 {
+			t.Errorf("%s: %d requests sent, want 2", tt.desc, requests)
+		}
+	}
+}
+
+func TestHTTPCacheCredentials(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		fmt.Fprint(w, r.Header.Get("Authorization"))
+	}))
+	defer server.Close()
+
+	cache := NewHTTPCache(t.TempDir())
+	for _, token := range []string{"Bearer a", "Bearer b", "Bearer a", "Bearer b"} {
+		req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+		req.Header.Set("Authorization", token)
+
+		resp, err := cache.Do(req, http.DefaultClient.Do)
+		if err != nil {
+			t.Fatalf("Do: %v", err)
+		}
+
+		// Each token revalidates its own response
+		if string(resp.Body) != token {
+			t.Errorf("response for %s = %q", token, resp.Body)
+		}
+	}
+}
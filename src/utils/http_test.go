@@ -0,0 +1,111 @@
+package utils
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestRetryDelay(t *testing.T) {
+	get, _ := http.NewRequest(http.MethodGet, "https://example.com", nil)
+	post, _ := http.NewRequest(http.MethodPost, "https://example.com", nil)
+	keyed, _ := http.NewRequest(http.MethodPost, "https://example.com", nil)
+	keyed.Header.Set("Idempotency-Key", "key")
+
+	timeout := &url.Error{Op: "Get", URL: "https://example.com", Err: &net.OpError{Op: "dial", Err: os.ErrDeadlineExceeded}}
+	reset := &url.Error{Op: "Get", URL: "https://example.com", Err: &net.OpError{Op: "read", Err: syscall.ECONNRESET}}
+	scheme := &url.Error{Op: "Get", URL: "ftp://example.com", Err: errors.New("unsupported protocol scheme \"ftp\"")}
+	cert := &url.Error{Op: "Get", URL: "https://example.com", Err: &tls.CertificateVerificationError{Err: x509.UnknownAuthorityError{}}}
+
+	response := func(status int, header http.Header) *http.Response {
+		if header == nil {
+			header = http.Header{}
+		}
+		return &http.Response{StatusCode: status, Header: header}
+	}
+
+	tests := []struct {
+		desc  string
+		req   *http.Request
+		resp  *http.Response
+		err   error
+		retry bool
+		wait  time.Duration
+	}{
+		{"success", get, response(http.StatusOK, nil), nil, false, 0},
+		{"not found", get, response(http.StatusNotFound, nil), nil, false, 0},
+		{"server error", get, response(http.StatusBadGateway, nil), nil, true, 0},
+		{"not implemented", get, response(http.StatusNotImplemented, nil), nil, false, 0},
+		{"server error on post", post, response(http.StatusBadGateway, nil), nil, false, 0},
+		{"server error with idempotency key", keyed, response(http.StatusBadGateway, nil), nil, true, 0},
+		{"too many requests on post", post, response(http.StatusTooManyRequests, http.Header{"Retry-After": {"7"}}), nil, true, 7 * time.Second},
+		{"retry after", get, response(http.StatusServiceUnavailable, http.Header{"Retry-After": {"3"}}), nil, true, 3 * time.Second},
+		{"retry after too long", get, response(http.StatusTooManyRequests, http.Header{"Retry-After": {"7200"}}), nil, false, 0},
+		{"rate limit exhausted", get, response(http.StatusForbidden, http.Header{"X-Ratelimit-Remaining": {"0"}}), nil, true, 0},
+		{"forbidden", get, response(http.StatusForbidden, nil), nil, false, 0},
+		{"timeout", get, nil, timeout, true, 0},
+		{"connection reset", get, nil, reset, true, 0},
+		{"unexpected eof", get, nil, io.ErrUnexpectedEOF, true, 0},
+		{"timeout on post", post, nil, timeout, false, 0},
+		{"unsupported scheme", get, nil, scheme, false, 0},
+		{"certificate verification", get, nil, cert, false, 0},
+		{"canceled", get, nil, &url.Error{Op: "Get", URL: "https://example.com", Err: context.Canceled}, false, 0},
+	}
+
+	for _, tt := range tests {
+		wait, retry := retryDelay(tt.req, tt.resp, tt.err, 1)
+		if retry != tt.retry {
+			t.Errorf("%s: retry = %t, want %t", tt.desc, retry, tt.retry)
+			continue
+		}
+
+		// Without a delay from the server, the backoff of the first attempt is up to baseDelay
+		if tt.wait > 0 && wait != tt.wait {
+			t.Errorf("%s: wait = %s, want %s", tt.desc, wait, tt.wait)
+		} else if tt.retry && tt.wait == 0 && (wait <= 0 || wait > baseDelay) {
+			t.Errorf("%s: wait = %s, want up to %s", tt.desc, wait, baseDelay)
+		}
+	}
+}
+
+func TestServerDelay(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		desc   string
+		header http.Header
+		ok     bool
+		min    time.Duration
+		max    time.Duration
+	}{
+		{"none", http.Header{}, false, 0, 0},
+		{"retry after seconds", http.Header{"Retry-After": {"10"}}, true, 10 * time.Second, 10 * time.Second},
+		{"retry after date", http.Header{"Retry-After": {now.Add(time.Minute).UTC().Format(http.TimeFormat)}}, true, 58 * time.Second, time.Minute},
+		{"retry after past date", http.Header{"Retry-After": {now.Add(-time.Minute).UTC().Format(http.TimeFormat)}}, true, 0, 0},
+		{"invalid retry after", http.Header{"Retry-After": {"soon"}}, false, 0, 0},
+		{"rate limit reset", http.Header{"X-Ratelimit-Remaining": {"0"}, "X-Ratelimit-Reset": {strconv.FormatInt(now.Add(time.Minute).Unix(), 10)}}, true, 59 * time.Second, 61 * time.Second},
+		{"rate limit not exhausted", http.Header{"X-Ratelimit-Remaining": {"10"}, "X-Ratelimit-Reset": {strconv.FormatInt(now.Add(time.Minute).Unix(), 10)}}, false, 0, 0},
+	}
+
+	for _, tt := range tests {
+		wait, ok := serverDelay(tt.header)
+		if ok != tt.ok {
+			t.Errorf("%s: ok = %t, want %t", tt.desc, ok, tt.ok)
+			continue
+		}
+
+		if wait < tt.min || wait > tt.max {
+			t.Errorf("%s: wait = %s, want between %s and %s", tt.desc, wait, tt.min, tt.max)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+var ErrLocked = errors.New("another run is in progress")
+
+// Lock takes an exclusive lock on the given file, failing with ErrLocked when
+// another process holds it. The returned function releases the lock.
+func Lock(path string) (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("creating lock directory: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("opening lock file: %w", err)
+	}
+
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		file.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, ErrLocked
+		}
+		return nil, fmt.Errorf("locking: %w", err)
+	}
+
+	return func() {
+		_ = syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+		file.Close()
+	}, nil
+}
+
+// WaitLock takes the lock like Lock, trying again every interval while another process holds it,
+// until the context is canceled.
+func WaitLock(ctx context.Context, path string, interval time.Duration) (func(), error) {
+	unlock, err := Lock(path)
+	for errors.Is(err, ErrLocked) {
+		if err := Sleep(ctx, interval); err != nil {
+			return nil, err
+		}
+		unlock, err = Lock(path)
+	}
+
+	return unlock, err
+}
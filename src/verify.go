@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// VerifyRepo compares the branches and tags of the source repository with its
+// GitLab backup and returns the differences found.
+func VerifyRepo(ctx context.Context, prj *Project) ([]string, error) {
+	repoID, err := prj.RetrieveExistingRepo(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if repoID == -1 {
+		return []string{"project does not exist in GitLab"}, nil
+	}
+
+	sourceRefs, err := ListRefs(ctx, prj.SourceRepository.URL)
+	if err != nil {
+		return nil, fmt.Errorf("listing source refs: %w", err)
+	}
+
+	destinationRefs, err := ListRefs(ctx, prj.GetDestinationURL())
+	if err != nil {
+		return nil, fmt.Errorf("listing GitLab refs: %w", err)
+	}
+
+	names := make([]string, 0, len(sourceRefs))
+	for name := range sourceRefs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	problems := make([]string, 0)
+	for _, name := range names {
+		dstHash, ok := destinationRefs[name]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s is missing", name))
+		} else if dstHash != sourceRefs[name] {
+			problems = append(problems, fmt.Sprintf("%s is at %s instead of %s", name, dstHash, sourceRefs[name]))
+		}
+	}
+
+	return problems, nil
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"main/src/sources"
+	"main/src/utils"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+)
+
+// WebhookJob is a single repository to sync, requested by a webhook.
+type WebhookJob struct {
+	Source   string
+	Username string
+	Remote   sources.SourceRepository
+}
+
+func (j WebhookJob) Key() string {
+	return strings.ToLower(fmt.Sprintf("%s/%s/%s", j.Source, j.Username, j.Remote.Name))
+}
+
+// webhookPayload holds the fields shared by GitHub and Gitea push and release events.
+type webhookPayload struct {
+	Action     string `json:"action"`
+	Repository struct {
+		Name          string   `json:"name"`
+		FullName      string   `json:"full_name"`
+		CloneURL      string   `json:"clone_url"`
+		OriginalURL   string   `json:"original_url"`
+		Description   *string  `json:"description"`
+		Homepage      *string  `json:"homepage"`
+		Website       *string  `json:"website"`
+		Topics        []string `json:"topics"`
+		DefaultBranch string   `json:"default_branch"`
+		Archived      *bool    `json:"archived"`
+		Owner         struct {
+			AvatarURL string `json:"avatar_url"`
+		} `json:"owner"`
+	} `json:"repository"`
+}
+
+// HandleWebhook receives GitHub and Gitea push and release events and enqueues
+// a sync of the repository they refer to.
+func (d *Daemon) HandleWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	config := d.GetConfig()
+	if config.Webhook.Secret == nil {
+		http.Error(w, "webhooks are not enabled", http.StatusNotFound)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, 25<<20))
+	if err != nil {
+		http.Error(w, "reading body", http.StatusBadRequest)
+		return
+	}
+
+	// Gitea also sends the GitHub headers, so its own header decides the provider
+	var event, signature string
+	gitea := len(r.Header.Get("X-Gitea-Event")) > 0
+	if gitea {
+		event, signature = r.Header.Get("X-Gitea-Event"), r.Header.Get("X-Gitea-Signature")
+	} else if event = r.Header.Get("X-GitHub-Event"); len(event) > 0 {
+		signature = strings.TrimPrefix(r.Header.Get("X-Hub-Signature-256"), "sha256=")
+	} else {
+		http.Error(w, "unknown webhook provider", http.StatusBadRequest)
+		return
+	}
+
+	if !verifySignature(*config.Webhook.Secret, body, signature) {
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	if event == "ping" {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if event != "push" && event != "release" {
+		http.Error(w, fmt.Sprintf("event %s is ignored", event), http.StatusAccepted)
+		return
+	}
+
+	var payload webhookPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+
+	if event == "release" && payload.Action == "deleted" {
+		http.Error(w, "deleted releases are ignored", http.StatusAccepted)
+		return
+	}
+
+	// Gitea hosts no source repository, only its mirrors of one, which are synced from the mirrored repository
+	cloneURL := payload.Repository.CloneURL
+	if gitea {
+		if len(payload.Repository.OriginalURL) == 0 {
+			http.Error(w, "gitea events are only accepted for mirrors of a source repository", http.StatusAccepted)
+			return
+		}
+		cloneURL = strings.TrimSuffix(payload.Repository.OriginalURL, "/")
+		if !strings.HasSuffix(cloneURL, ".git") {
+			cloneURL += ".git"
+		}
+	}
+
+	group := d.findWebhookGroup(config, cloneURL)
+	if group == nil {
+		http.Error(w, fmt.Sprintf("repository %s is not configured", cloneURL), http.StatusNotFound)
+		return
+	}
+
+	// Gitea names the homepage website
+	homepage := payload.Repository.Homepage
+	if homepage == nil {
+		homepage = payload.Repository.Website
+	}
+
+	// The avatar of the Gitea owner is not the one of the source
+	avatar := ""
+	if !gitea {
+		avatar = sources.GitHubAvatarURL(payload.Repository.Owner.AvatarURL)
+	}
+
+	job := WebhookJob{
+		Source:   group.Source,
+		Username: group.Username,
+		Remote: sources.SourceRepository{
+			Name:          path.Base(strings.TrimSuffix(cloneURL, ".git")),
+			URL:           cloneURL,
+			Description:   payload.Repository.Description,
+			Homepage:      homepage,
+			Topics:        payload.Repository.Topics,
+			DefaultBranch: payload.Repository.DefaultBranch,
+			Archived:      payload.Repository.Archived,
+			AvatarURL:     avatar,
+		},
+	}
+
+	if d.Enqueue(job) {
+		slog.Info("Queued sync from webhook", "event", event, "source", job.Source, "group", job.Username, "repo", job.Remote.Name)
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func verifySignature(secret string, body []byte, signature string) bool {
+	expected, err := hex.DecodeString(signature)
+	if err != nil || len(expected) == 0 {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+// findWebhookGroup finds the configured group the repository belongs to, from the source host and
+// the owner of its clone url.
+func (d *Daemon) findWebhookGroup(config *Configuration, cloneURL string) *ConfigGroup {
+	repoName := path.Base(strings.TrimSuffix(cloneURL, ".git"))
+
+	for i := range config.Groups {
+		group := &config.Groups[i]
+
+		if !ownedBy(cloneURL, group.Source, group.Username) || !d.Options.MatchGroup(*group) || !d.Options.MatchRepository(repoName) {
+			continue
+		}
+
+		return group
+	}
+
+	return nil
+}
+
+// Enqueue adds a job to the webhook queue, unless the same repository is already waiting.
+func (d *Daemon) Enqueue(job WebhookJob) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.pending[job.Key()] {
+		return false
+	}
+
+	select {
+	case d.queue <- job:
+		d.pending[job.Key()] = true
+		return true
+	default:
+		slog.Warn("Webhook queue is full, dropping sync", "source", job.Source, "group", job.Username, "repo", job.Remote.Name)
+		return false
+	}
+}
+
+// RunQueue syncs the repositories requested by webhooks, one at a time, until the context is canceled.
+func (d *Daemon) RunQueue(ctx context.Context) {
+	for {
+		var job WebhookJob
+		select {
+		case <-ctx.Done():
+			return
+		case job = <-d.queue:
+		}
+
+		d.mu.Lock()
+		delete(d.pending, job.Key())
+		d.mu.Unlock()
+
+		if err := d.syncWebhookJob(ctx, job); err != nil {
+			slog.Error("Webhook sync failed", "source", job.Source, "group", job.Username, "repo", job.Remote.Name, "error", err)
+		}
+	}
+}
+
+func (d *Daemon) syncWebhookJob(ctx context.Context, job WebhookJob) error {
+	config := d.GetConfig()
+
+	var group *ConfigGroup
+	for i := range config.Groups {
+		if config.Groups[i].Source == job.Source && config.Groups[i].Username == job.Username {
+			group = &config.Groups[i]
+			break
+		}
+	}
+	if group == nil {
+		return fmt.Errorf("group is no longer configured")
+	}
+
+	// Wait for any scheduled run to finish
+	unlock, err := utils.WaitLock(ctx, LockPath, lockRetryDelay)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	gitlab, dufs := newDestinations(config)
+	source := newSources(config)[group.Source]
+
+	Status.StartRun("webhook")
+	defer func() {
+		report := Status.FinishRun()
+		WriteReports(config, report)
+		Notify(config, report)
+	}()
+
+	resolved, err := resolveGroup(ctx, gitlab, *group, true)
+	if err != nil {
+		Status.FailGroup(group.Source, group.Username, fmt.Errorf("resolving GitLab group: %w", err))
+		return err
+	}
+
+	// Collisions are detected over the complete listing, as in a sync
+	name, err := webhookProjectName(ctx, gitlab, source, resolved, job.Remote)
+	if err != nil {
+		Status.FailGroup(group.Source, group.Username, fmt.Errorf("naming project: %w", err))
+		return err
+	}
+
+	resolved, err = repositoryGroup(ctx, gitlab, resolved, job.Remote, true)
+	if err != nil {
+		Status.FailGroup(group.Source, group.Username, fmt.Errorf("resolving GitLab group: %w", err))
+		return err
+	}
+	group = &resolved
+
+	// The position of the repository is unknown, so "skip" does not apply
+	cfg, reason, _ := resolveRemote(config.GetSourceConfig(group.Source), *group, job.Remote, *group.Skip+1)
+	if len(reason) > 0 {
+		slog.Info("Skipping repository", "source", group.Source, "group", group.Username, "repo", job.Remote.Name, "reason", reason)
+		Status.SkipRepo(group.Source, group.Username, job.Remote.Name, reason)
+		return nil
+	}
+
+	slog.Info("Evaluating repository from webhook", "source", group.Source, "group", group.Username, "repo", job.Remote.Name)
+	Status.StartRepo(group.Source, group.Username, job.Remote.Name)
+
+	prj := NewProject(gitlab, dufs, *group.GitLabGroupID, name, source, group.Username, job.Remote, cfg)
+	prj.Only = d.Options.Only
+	defer prj.Prune()
+
+	err = SyncRepo(ctx, prj)
+	Status.FinishRepo(prj.Result, err)
+	return err
+}
+
+// webhookProjectName returns the GitLab name of the repository of a webhook event, mapped with the
+// other repositories and the projects of its group. resolveGroup must be called first.
+func webhookProjectName(ctx context.Context, gitlab *GitLab, source sources.Source, groupCfg ConfigGroup, remote sources.SourceRepository) (string, error) {
+	remotes, err := listRepositories(ctx, source, groupCfg.Username)
+	if err != nil {
+		return "", fmt.Errorf("paginating repositories: %w", err)
+	}
+
+	projects, err := listGroupProjects(ctx, gitlab, groupCfg)
+	if err != nil {
+		return "", err
+	}
+
+	// The listing may not have caught up with a repository created right before the event
+	idx := slices.IndexFunc(remotes, func(r sources.SourceRepository) bool {
+		return normalizeURL(r.URL) == normalizeURL(remote.URL)
+	})
+	if idx == -1 {
+		remotes = append(remotes, remote)
+		idx = len(remotes) - 1
+	}
+
+	return projectNames(gitlab, groupCfg, remotes, projects)[remotes[idx].URL], nil
+}
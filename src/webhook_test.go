@@ -0,0 +1,109 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"main/src/sources"
+	"main/src/utils"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func sign(secret, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifySignature(t *testing.T) {
+	body := `{"repository": {"name": "project"}}`
+
+	tests := []struct {
+		desc      string
+		secret    string
+		body      string
+		signature string
+		want      bool
+	}{
+		{"valid", "secret", body, sign("secret", body), true},
+		{"uppercase hex", "secret", body, strings.ToUpper(sign("secret", body)), true},
+		{"wrong secret", "other", body, sign("secret", body), false},
+		{"tampered body", "secret", body + " ", sign("secret", body), false},
+		{"prefixed", "secret", body, "sha256=" + sign("secret", body), false},
+		{"truncated", "secret", body, sign("secret", body)[:32], false},
+		{"not hex", "secret", body, "not a signature", false},
+		{"empty", "secret", body, "", false},
+	}
+
+	for _, tt := range tests {
+		if got := verifySignature(tt.secret, []byte(tt.body), tt.signature); got != tt.want {
+			t.Errorf("%s: verifySignature = %t, want %t", tt.desc, got, tt.want)
+		}
+	}
+}
+
+func TestHandleWebhook(t *testing.T) {
+	config := &Configuration{
+		Webhook: ConfigWebhook{Secret: utils.Pointer("secret")},
+		Groups: []ConfigGroup{
+			{Source: sources.HuggingFaceID, Username: "alice"},
+			{Source: sources.GitHubID, Username: "alice"},
+		},
+	}
+
+	github := `{"repository": {"name": "project", "full_name": "alice/project", "clone_url": "https://github.com/alice/project.git"}}`
+	other := `{"repository": {"name": "project", "full_name": "bob/project", "clone_url": "https://github.com/bob/project.git"}}`
+	giteaMirror := `{"repository": {"name": "mirror", "full_name": "alice/mirror", "clone_url": "https://gitea.example.com/alice/mirror.git", "original_url": "https://github.com/Alice/project"}}`
+	gitea := `{"repository": {"name": "project", "full_name": "alice/project", "clone_url": "https://gitea.example.com/alice/project.git", "original_url": ""}}`
+
+	tests := []struct {
+		desc    string
+		headers map[string]string
+		body    string
+		status  int
+		url     string
+	}{
+		{"github push", map[string]string{"X-GitHub-Event": "push", "X-Hub-Signature-256": "sha256=" + sign("secret", github)}, github, http.StatusAccepted, "https://github.com/alice/project.git"},
+		{"github invalid signature", map[string]string{"X-GitHub-Event": "push", "X-Hub-Signature-256": "sha256=" + sign("other", github)}, github, http.StatusUnauthorized, ""},
+		{"github other owner", map[string]string{"X-GitHub-Event": "push", "X-Hub-Signature-256": "sha256=" + sign("secret", other)}, other, http.StatusNotFound, ""},
+		{"github ping", map[string]string{"X-GitHub-Event": "ping", "X-Hub-Signature-256": "sha256=" + sign("secret", github)}, github, http.StatusNoContent, ""},
+		{"gitea mirror", map[string]string{"X-Gitea-Event": "push", "X-Gitea-Signature": sign("secret", giteaMirror)}, giteaMirror, http.StatusAccepted, "https://github.com/Alice/project.git"},
+		{"gitea mirror with github headers", map[string]string{"X-GitHub-Event": "push", "X-Hub-Signature-256": "sha256=" + sign("other", giteaMirror), "X-Gitea-Event": "push", "X-Gitea-Signature": sign("secret", giteaMirror)}, giteaMirror, http.StatusAccepted, "https://github.com/Alice/project.git"},
+		{"gitea mirror with github signature", map[string]string{"X-GitHub-Event": "push", "X-Hub-Signature-256": "sha256=" + sign("secret", giteaMirror), "X-Gitea-Event": "push"}, giteaMirror, http.StatusUnauthorized, ""},
+		{"gitea repository", map[string]string{"X-Gitea-Event": "push", "X-Gitea-Signature": sign("secret", gitea)}, gitea, http.StatusAccepted, ""},
+		{"gitea invalid signature", map[string]string{"X-Gitea-Event": "push", "X-Gitea-Signature": sign("other", giteaMirror)}, giteaMirror, http.StatusUnauthorized, ""},
+		{"unknown provider", map[string]string{"X-Gitlab-Event": "Push Hook", "X-Gitlab-Token": "secret"}, github, http.StatusBadRequest, ""},
+	}
+
+	for _, tt := range tests {
+		d := NewDaemon(config, &Options{})
+
+		req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(tt.body))
+		for key, value := range tt.headers {
+			req.Header.Set(key, value)
+		}
+
+		rec := httptest.NewRecorder()
+		d.HandleWebhook(rec, req)
+		if rec.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d: %s", tt.desc, rec.Code, tt.status, rec.Body)
+			continue
+		}
+
+		select {
+		case job := <-d.queue:
+			if len(tt.url) == 0 {
+				t.Errorf("%s: unexpected sync of %s", tt.desc, job.Remote.URL)
+			} else if job.Source != sources.GitHubID || job.Username != "alice" || job.Remote.URL != tt.url || job.Remote.Name != "project" {
+				t.Errorf("%s: job = %s/%s %s (%s), want github/alice %s (project)", tt.desc, job.Source, job.Username, job.Remote.URL, job.Remote.Name, tt.url)
+			}
+		default:
+			if len(tt.url) > 0 {
+				t.Errorf("%s: no sync was queued", tt.desc)
+			}
+		}
+	}
+}